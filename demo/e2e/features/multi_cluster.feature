@@ -0,0 +1,18 @@
+@topology-hub-spoke
+Feature: Resource creation in a multi-cluster topology
+
+    Scenario: Resources are created in the addressed cluster
+        When Resource is created in cluster "spoke-1":
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: spoke-config
+        """
+        Then Resource exists in cluster "spoke-1":
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: spoke-config
+        """
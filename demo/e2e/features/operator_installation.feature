@@ -29,3 +29,20 @@ Feature: Operator installation
             name: controller-manager
             namespace: system
         """
+
+    Scenario: Operator's deployment is ready
+        When Operator "show" is installed
+        Then Resource satisfies "status.readyReplicas == spec.replicas":
+        """
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+            name: controller-manager
+        """
+        And Resource satisfies JSONPath "{.status.conditions[?(@.type=='Available')].status} == True":
+        """
+        apiVersion: apps/v1
+        kind: Deployment
+        metadata:
+            name: controller-manager
+        """
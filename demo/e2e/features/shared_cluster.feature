@@ -25,3 +25,114 @@ Feature: Resource creation in Cluster shared among multiple scenario
         metadata:
             name: notpermitted
         """
+
+    Scenario: Resources can refer to scenario variables
+        Given Resource is created:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: source
+            data:
+                namespace: ${scenario.namespace}
+        """
+        When Field "{.metadata.uid}" of resource is stored in variable "source.uid":
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: source
+        """
+        And Resource is created:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: copy-${scenario.id}
+            data:
+                namespace: ${scenario.namespace}
+                source: ${source.uid}
+        """
+        Then Resource exists in scenario namespace:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: copy-${scenario.id}
+        """
+
+    @namespace-workload
+    Scenario: Resources can be created in the scenario's additional namespaces
+        When Resources are created:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: operator-config
+            ---
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: workload-config
+                namespace: ${namespaces.workload}
+        """
+        Then Resources exist:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: operator-config
+                namespace: ${scenario.namespace}
+            ---
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: workload-config
+                namespace: ${namespaces.workload}
+        """
+        And Resource can not be created:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: outside
+                namespace: default
+        """
+
+    @api-faults
+    Scenario: Steps retry on a flaky API server
+        Given API server responds with status 503 to the next 2 "create" requests on "configmaps"
+        And API server delays "get" requests on "configmaps" by "500ms"
+        When Resource is created:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: flaky
+        """
+        Then Resource exists:
+        """
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+                name: flaky
+        """
+
+    Scenario: Pods logs and commands can be checked
+        When Resource is created:
+        """
+            apiVersion: v1
+            kind: Pod
+            metadata:
+                name: echo
+            spec:
+                containers:
+                - name: echo
+                  image: busybox
+                  command: ["sh", "-c", "echo ready; sleep 3600"]
+        """
+        Then Container "echo" of pod "echo" logs a line matching "^ready$"
+        And Command "cat /etc/hostname" in pod "echo" outputs:
+        """
+        echo
+        """
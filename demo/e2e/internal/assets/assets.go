@@ -1,6 +1,9 @@
 package assets
 
-import _ "embed"
+import "embed"
 
 //go:embed config/cluster/kind/default-host-cluster.yaml
 var DefaultClusterSpec string
+
+//go:embed config/topology/*.yaml
+var Topologies embed.FS
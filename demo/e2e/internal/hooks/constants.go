@@ -1,8 +1,30 @@
 package hooks
 
 const (
-	TagDedicatedCluster         = "@dedicated-cluster"
+	TagDedicatedCluster = "@dedicated-cluster"
+	// scenarios tagged with @api-faults reach the scenario cluster through a fault injection proxy
+	tagAPIFaults                = "@api-faults"
 	tagClusterProvisionerPrefix = "cluster-provisioner-"
+	// scenarios tagged with @topology-<name> get the clusters declared in config/topology/<name>.yaml
+	tagTopologyPrefix = "@topology-"
+	// scenarios tagged with @namespace-<name> get the additional namespace test-<scenario-id>-<name>
+	tagNamespacePrefix = "@namespace-"
 
 	defaultClusterProvisioner = "default"
+	namespaceProvisioner      = "scenario-namespace"
+
+	// directory containing the kubeconfigs of pre-existing clusters to use instead of provisioning new ones
+	envKubeconfigDir = "MCTEST_KUBECONFIG_DIR"
+	// namespace of the management cluster in which pre-existing clusters are leased, if set
+	envLeaseNamespace = "MCTEST_LEASE_NAMESPACE"
+	// if set to true, request and response bodies are recorded together with the scenario's API calls
+	envRecordBodies = "MCTEST_RECORD_BODIES"
+	// host the fault injection proxy is reached at from the scenario cluster's pods, e.g. host.docker.internal.
+	// If set, the proxy listens on all interfaces.
+	envAPIProxyHost = "MCTEST_API_PROXY_HOST"
+	// directory the scenarios' HTTP interactions are recorded to as cassettes, if set
+	envCassetteDir = "MCTEST_CASSETTE_DIR"
+
+	// file in the scenario's test folder the API calls are recorded to
+	apiCallsFile = "api-calls.jsonl"
 )
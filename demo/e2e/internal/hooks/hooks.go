@@ -25,6 +25,24 @@ func injectHookSetup(ctx *godog.ScenarioContext) {
 	// prepare the test environment
 	ctx.Before(prepareTestEnvironment)
 
+	// create the scenario's additional namespaces, if any
+	ctx.Before(prepareScenarioNamespaces)
+
+	// provision the scenario's topology, if any
+	ctx.Before(provisionTopology)
+
+	// inject the scenario's variables
+	ctx.Before(injectVariables)
+
+	// put the scenario cluster behind a fault injection proxy, if requested
+	ctx.Before(proxyScenarioCluster)
+
+	// record the API calls of the scenario's clients
+	ctx.Before(recordAPICalls)
+
+	// record the HTTP interactions of the scenario's clients into a cassette, if requested
+	ctx.Before(recordCassette)
+
 	// set timeout for single test
 	ctx.Before(setTimeout)
 }
@@ -33,12 +51,27 @@ func injectHookCleanup(ctx *godog.ScenarioContext) {
 	// cancel run context
 	ctx.After(cancelRunContext)
 
+	// stop the fault injection proxy
+	ctx.After(closeAPIProxy)
+
+	// unregister the clusters registered by the scenario
+	ctx.After(unregisterClusters)
+
+	// unprovision topology's clusters
+	ctx.After(unprovisionTopology)
+
 	// unprovision clusters
 	ctx.After(unprovisionClusters)
 
 	// delete the ContextNamespace if no errors occurred
 	ctx.After(destroyHostResources)
 
+	// close the API calls recorder
+	ctx.After(closeAPIRecorder)
+
+	// save the scenario's cassette
+	ctx.After(saveCassette)
+
 	// cleanup temp folder
 	ctx.After(destroyScenarioTestFolder)
 }
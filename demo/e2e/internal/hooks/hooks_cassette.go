@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/topology"
+)
+
+// recordCassette replaces the scenario's clients, i.e. the scenario cluster and the topology's clusters,
+// with clients recording their HTTP interactions into a cassette named after the scenario.
+// Cassettes can then be replayed by step libraries' tests, with no cluster.
+func recordCassette(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	d := os.Getenv(envCassetteDir)
+	if d == "" {
+		return ctx, nil
+	}
+
+	sk, err := einfra.ScenarioClusterFromContext(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	c, err := kube.NewCassette(path.Join(d, cassetteName(sc)), kube.CassetteRecord)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = einfra.CassetteIntoContext(ctx, c)
+
+	k, err := c.WrapClient(sk)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = einfra.ScenarioClusterIntoContext(ctx, k)
+
+	cc, err := topology.ClustersFromContext(ctx)
+	switch {
+	case errors.Is(err, econtext.ErrKeyNotFound):
+		// no topology for the scenario
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	rcc := make(map[string]kube.Client, len(cc))
+	for n, k := range cc {
+		rk, err := c.WrapClient(k)
+		if err != nil {
+			return ctx, err
+		}
+		rcc[n] = rk
+	}
+	return topology.ClustersIntoContext(ctx, rcc), nil
+}
+
+func saveCassette(ctx context.Context, _ *godog.Scenario, err error) (context.Context, error) {
+	c, cerr := einfra.CassetteFromContext(ctx)
+	if cerr != nil {
+		// no cassette for the scenario
+		return ctx, err
+	}
+
+	if serr := c.Save(); serr != nil {
+		log.Printf("error saving cassette %s: %v", c.Path, serr)
+	}
+	return ctx, err
+}
+
+// cassetteName returns the cassette's file name, made of the feature file's and the scenario's names
+func cassetteName(sc *godog.Scenario) string {
+	f := strings.TrimSuffix(path.Base(sc.Uri), path.Ext(sc.Uri))
+	n := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '-'
+		}
+	}, f+"-"+sc.Name)
+	return n + ".yaml"
+}
@@ -28,6 +28,35 @@ func cancelRunContext(ctx context.Context, _ *godog.Scenario, err error) (contex
 	return ctx, err
 }
 
+func unregisterClusters(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+	// if an error occurred before, do not cleanup
+	if err != nil {
+		return ctx, nil
+	}
+
+	rr, err := infra.RegistrationsFromContext(ctx)
+	if err != nil {
+		// no cluster registered by the scenario
+		return ctx, nil
+	}
+
+	lctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	errs := []error{}
+	for _, r := range rr {
+		if err := r.Unregister(lctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("error unregistering clusters: %v", err)
+		return ctx, err
+	}
+	return ctx, nil
+}
+
 func unprovisionClusters(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
 	// if an error occurred before, do not cleanup
 	if err != nil {
@@ -90,7 +119,12 @@ func destroyHostResources(ctx context.Context, sc *godog.Scenario, err error) (c
 
 	// delete all namespaces related to current scenario
 	for _, n := range nn.Items {
-		if errDel := kh.Delete(lctx, &n, &client.DeleteOptions{}); errDel != nil {
+		// skip namespaces already being deleted, e.g. by provisioners
+		if n.DeletionTimestamp != nil {
+			continue
+		}
+
+		if errDel := kh.Delete(lctx, &n, &client.DeleteOptions{}); client.IgnoreNotFound(errDel) != nil {
 			cerr := errors.Join(err, errDel)
 			log.Printf("error destroying namespace %s in management cluster: %s", n.Name, cerr)
 			return ctx, cerr
@@ -0,0 +1,58 @@
+package hooks
+
+import (
+	"context"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/faults"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// proxyScenarioCluster replaces the scenario cluster with a client reaching it through a fault injection proxy.
+func proxyScenarioCluster(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	if !slices.ContainsFunc(sc.Tags, func(t *messages.PickleTag) bool { return t.Name == tagAPIFaults }) {
+		return ctx, nil
+	}
+
+	k, err := einfra.ScenarioClusterFromContext(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	opts := faults.ProxyOptions{}
+	if h := os.Getenv(envAPIProxyHost); h != "" {
+		opts.Address, opts.AdvertisedHost = "0.0.0.0:0", h
+	}
+	p, err := faults.StartWithOptions(ctx, k.RESTConfig(), opts)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx = einfra.APIProxyIntoContext(ctx, p)
+
+	pk, err := kube.WithRESTConfig(k, p.RESTConfig())
+	if err != nil {
+		return ctx, err
+	}
+
+	return einfra.ScenarioClusterIntoContext(ctx, pk), nil
+}
+
+func closeAPIProxy(ctx context.Context, _ *godog.Scenario, err error) (context.Context, error) {
+	p, perr := einfra.APIProxyFromContext(ctx)
+	if perr != nil {
+		// no proxy for the scenario
+		return ctx, err
+	}
+
+	if cerr := p.Close(); cerr != nil {
+		log.Printf("error closing fault injection proxy: %v", cerr)
+	}
+	return ctx, err
+}
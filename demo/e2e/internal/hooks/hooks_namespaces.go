@@ -0,0 +1,120 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cucumber/godog"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/demo/e2e/internal/scheme"
+	"github.com/filariow/mctest/pkg/infra/namespace"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// prepareScenarioNamespaces creates the additional namespaces requested with @namespace-<name> tags
+// and replaces the scenario cluster with a client scoped to all of the scenario's namespaces.
+// On shared clusters the scenario namespace is the default one, and the scenario's
+// ServiceAccount is granted access to the additional namespaces.
+func prepareScenarioNamespaces(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	nn := scenarioNamespaces(sc)
+	if len(nn) == 0 {
+		return ctx, nil
+	}
+
+	m := map[string]string{}
+	ss := make([]string, 0, len(nn))
+	for _, n := range nn {
+		m[n] = fmt.Sprintf("test-%s-%s", sc.Id, n)
+		ss = append(ss, m[n])
+	}
+
+	k, err := func() (kube.Client, error) {
+		if isDedicatedClusterRequired(sc) {
+			return einfra.ScenarioClusterFromContext(ctx)
+		}
+		return einfra.ManagementClusterFromContext(ctx)
+	}()
+	if err != nil {
+		return ctx, err
+	}
+
+	if _, err := kube.CreateNamespacesWithLabels(ctx, k, ss, scenarioLabels(sc.Id)); err != nil {
+		return ctx, err
+	}
+
+	cfg, nss, err := func() (*rest.Config, []string, error) {
+		if isDedicatedClusterRequired(sc) {
+			return k.RESTConfig(), ss, nil
+		}
+		return grantScenarioNamespaces(ctx, k, ss, scenarioLabels(sc.Id))
+	}()
+	if err != nil {
+		return ctx, err
+	}
+
+	mk, err := kube.NewMultiNamespaced(cfg, client.Options{Scheme: scheme.DefaultSchemeHost}, nss)
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx = einfra.ScenarioNamespacesIntoContext(ctx, m)
+	return einfra.ScenarioClusterIntoContext(ctx, mk), nil
+}
+
+// grantScenarioNamespaces grants the scenario namespace's ServiceAccount admin access to the given namespaces.
+// It returns the ServiceAccount's rest.Config and the namespaces, scenario namespace first.
+func grantScenarioNamespaces(ctx context.Context, k kube.Client, namespaces []string, labels map[string]string) (*rest.Config, []string, error) {
+	pp, err := einfra.ProvisionersFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	np, ok := pp[namespaceProvisioner].(*namespace.NamespaceProvisioner)
+	if !ok {
+		return nil, nil, fmt.Errorf("provisioner %s not found", namespaceProvisioner)
+	}
+
+	for _, n := range namespaces {
+		om := metav1.ObjectMeta{Name: np.ServiceAccountName, Namespace: n, Labels: labels}
+		oo := []client.Object{
+			&rbacv1.Role{ObjectMeta: om, Rules: namespace.AdminRules},
+			&rbacv1.RoleBinding{
+				ObjectMeta: om,
+				Subjects: []rbacv1.Subject{
+					{Kind: rbacv1.ServiceAccountKind, Name: np.ServiceAccountName, Namespace: np.Namespace},
+				},
+				RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: np.ServiceAccountName},
+			},
+		}
+		for _, o := range oo {
+			if err := k.Create(ctx, o, &client.CreateOptions{}); err != nil {
+				return nil, nil, fmt.Errorf("error creating %T %s/%s: %w", o, o.GetNamespace(), o.GetName(), err)
+			}
+		}
+	}
+
+	srcs, err := np.GetAllCredentialSources(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := srcs[np.Namespace].RESTConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, append([]string{np.Namespace}, namespaces...), nil
+}
+
+func scenarioNamespaces(sc *godog.Scenario) []string {
+	nn := []string{}
+	for _, t := range sc.Tags {
+		if n, ok := strings.CutPrefix(t.Name, tagNamespacePrefix); ok {
+			nn = append(nn, n)
+		}
+	}
+	return nn
+}
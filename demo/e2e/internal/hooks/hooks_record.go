@@ -0,0 +1,77 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/cucumber/godog"
+
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/testrun"
+	"github.com/filariow/mctest/pkg/topology"
+)
+
+// recordAPICalls replaces the scenario's clients, i.e. the scenario cluster and the topology's clusters,
+// with clients recording their API calls into the scenario's test folder
+func recordAPICalls(ctx context.Context, _ *godog.Scenario) (context.Context, error) {
+	tf, err := testrun.TestFolderFromContext(ctx)
+	if err != nil {
+		return ctx, errors.Join(testrun.ErrTestFolderNotFound, err)
+	}
+
+	sk, err := einfra.ScenarioClusterFromContext(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	rb, _ := strconv.ParseBool(os.Getenv(envRecordBodies))
+	r, err := kube.NewFileRecorder(path.Join(tf, apiCallsFile), kube.RecorderOptions{RecordBodies: rb})
+	if err != nil {
+		return ctx, err
+	}
+	ctx = einfra.APIRecorderIntoContext(ctx, r)
+
+	k, err := r.WrapClient(sk)
+	if err != nil {
+		return ctx, err
+	}
+	ctx = einfra.ScenarioClusterIntoContext(ctx, k)
+
+	cc, err := topology.ClustersFromContext(ctx)
+	switch {
+	case errors.Is(err, econtext.ErrKeyNotFound):
+		// no topology for the scenario
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	rcc := make(map[string]kube.Client, len(cc))
+	for n, c := range cc {
+		rc, err := r.WrapClient(c)
+		if err != nil {
+			return ctx, err
+		}
+		rcc[n] = rc
+	}
+	return topology.ClustersIntoContext(ctx, rcc), nil
+}
+
+func closeAPIRecorder(ctx context.Context, _ *godog.Scenario, err error) (context.Context, error) {
+	r, rerr := einfra.APIRecorderFromContext(ctx)
+	if rerr != nil {
+		// recorder not created
+		return ctx, err
+	}
+
+	if cerr := r.Close(); cerr != nil {
+		log.Printf("error closing API calls recorder: %v", cerr)
+	}
+	return ctx, err
+}
@@ -12,9 +12,7 @@ import (
 	"github.com/cucumber/godog"
 	messages "github.com/cucumber/messages/go/v21"
 	cp "github.com/otiai10/copy"
-	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
-	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/rest"
@@ -26,6 +24,9 @@ import (
 	econtext "github.com/filariow/mctest/pkg/context"
 	"github.com/filariow/mctest/pkg/infra"
 	"github.com/filariow/mctest/pkg/infra/clusterapi"
+	"github.com/filariow/mctest/pkg/infra/lease"
+	"github.com/filariow/mctest/pkg/infra/namespace"
+	"github.com/filariow/mctest/pkg/infra/static"
 	"github.com/filariow/mctest/pkg/kube"
 	"github.com/filariow/mctest/pkg/testrun"
 )
@@ -36,6 +37,26 @@ func setTimeout(ctx context.Context, _ *godog.Scenario) (context.Context, error)
 }
 
 func injectProvisioners(ctx context.Context, s *godog.Scenario) (context.Context, error) {
+	// use pre-existing clusters, if configured
+	if d := os.Getenv(envKubeconfigDir); d != "" {
+		sp, err := static.NewFromDirectory(d, nil)
+		if err != nil {
+			return ctx, err
+		}
+
+		// lease clusters shared among multiple jobs
+		if ln := os.Getenv(envLeaseNamespace); ln != "" {
+			k, err := einfra.ManagementClusterFromContext(ctx)
+			if err != nil {
+				return ctx, err
+			}
+			sp = lease.NewLeasedProvisioner(k, ln, "", sp)
+		}
+
+		hostProvisioners := map[string]infra.ClusterProvisioner{defaultClusterProvisioner: sp}
+		return einfra.ProvisionersIntoContext(ctx, hostProvisioners), nil
+	}
+
 	k, err := einfra.ManagementClusterFromContext(ctx)
 	if err != nil {
 		return ctx, err
@@ -167,17 +188,35 @@ func prepareDedicatedTestEnvironment(ctx context.Context, sc *godog.Scenario) (c
 
 func prepareTestEnvironmentOnManagementCluster(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
 	h, err := einfra.ManagementClusterFromContext(ctx)
+	if err != nil {
+		return ctx, err
+	}
 
-	n, err := createTestNamespace(ctx, h, sc.Id)
+	// provision the scenario namespace
+	p := namespace.NewNamespaceProvisioner(h, fmt.Sprintf("test-%s", sc.Id), scenarioLabels(sc.Id))
+	if err := p.Provision(ctx); err != nil {
+		return ctx, err
+	}
+	if err := p.WaitForProvisionedClusters(ctx); err != nil {
+		return ctx, err
+	}
+
+	// register the provisioner for cleanup
+	pp, err := einfra.ProvisionersFromContext(ctx)
 	if err != nil {
 		return ctx, err
 	}
+	pp[namespaceProvisioner] = p
 
 	// inject scenario namespace in context
-	ctx = einfra.ScenarioNamespaceIntoContext(ctx, n.Name)
+	ctx = einfra.ScenarioNamespaceIntoContext(ctx, p.Namespace)
 
-	// inject a namespaced client
-	nk, err := prepareNamespacedClient(ctx, h, n.Name)
+	// inject a namespaced client authenticating with refreshed ServiceAccount tokens
+	srcs, err := p.GetAllCredentialSources(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	nk, err := kube.NewNamespacedFromCredentials(srcs[p.Namespace], client.Options{Scheme: scheme.DefaultSchemeHost}, p.Namespace)
 	if err != nil {
 		return ctx, err
 	}
@@ -186,10 +225,6 @@ func prepareTestEnvironmentOnManagementCluster(ctx context.Context, sc *godog.Sc
 	return einfra.ScenarioClusterIntoContext(ctx, nk), nil
 }
 
-func createTestNamespace(ctx context.Context, cluster kube.Client, scenarioId string) (*corev1.Namespace, error) {
-	return createNamespace(ctx, cluster, "test", scenarioId)
-}
-
 func createAuxiliaryTestNamespace(ctx context.Context, cluster kube.Client, scenarioId string) (*corev1.Namespace, error) {
 	return createNamespace(ctx, cluster, "test-aux", scenarioId)
 }
@@ -197,93 +232,19 @@ func createAuxiliaryTestNamespace(ctx context.Context, cluster kube.Client, scen
 func createNamespace(ctx context.Context, cluster kube.Client, prefix, scenarioId string) (*corev1.Namespace, error) {
 	ns := corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("%s-%s", prefix, scenarioId),
-			Labels: map[string]string{
-				"scope":    "test",
-				"scenario": scenarioId,
-			},
+			Name:   fmt.Sprintf("%s-%s", prefix, scenarioId),
+			Labels: scenarioLabels(scenarioId),
 		},
 	}
 	err := cluster.Create(ctx, &ns, &client.CreateOptions{})
 	return &ns, err
 }
 
-func prepareNamespacedClient(ctx context.Context, k kube.Client, ns string) (kube.Client, error) {
-	var err error
-
-	// get clientset
-	cli, err := k.Clientset()
-	if err != nil {
-		return nil, err
-	}
-
-	// create service account
-	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "test-runner", Namespace: ns}}
-	if err = k.Create(ctx, sa, &client.CreateOptions{}); err != nil {
-		return nil, err
-	}
-
-	// create token
-	r := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{}}
-	if d, ok := ctx.Deadline(); ok {
-		es := int64(d.Sub(time.Now()).Seconds())
-		r.Spec.ExpirationSeconds = &es
-	}
-	r, err = cli.CoreV1().ServiceAccounts(ns).CreateToken(ctx, sa.Name, r, metav1.CreateOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	// create admin role
-	ro := rbacv1.Role{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      sa.Name,
-			Namespace: ns,
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				Verbs:     []string{"*"},
-				APIGroups: []string{"*"},
-				Resources: []string{"*"},
-				// ResourceNames: []string{},
-			},
-		},
-	}
-	if k.Create(ctx, &ro, &client.CreateOptions{}); err != nil {
-		return nil, err
-	}
-
-	// create rolebinding
-	rb := rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      sa.Name,
-			Namespace: ns,
-		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				APIGroup:  "",
-				Name:      sa.Name,
-				Namespace: ns,
-			},
-		},
-		RoleRef: rbacv1.RoleRef{
-			APIGroup: "rbac.authorization.k8s.io",
-			Kind:     "Role",
-			Name:     ro.Name,
-		},
-	}
-	if err := k.Create(ctx, &rb, &client.CreateOptions{}); err != nil {
-		return nil, err
-	}
-
-	// bake client
-	cfg := k.RESTConfig()
-	cfg.Impersonate = rest.ImpersonationConfig{
-		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", ns, sa.Name),
-		UID:      string(sa.GetUID()),
+func scenarioLabels(scenarioId string) map[string]string {
+	return map[string]string{
+		"scope":    "test",
+		"scenario": scenarioId,
 	}
-	return kube.NewNamespaced(cfg, client.Options{Scheme: scheme.DefaultSchemeHost}, ns)
 }
 
 func hookPrepareScenarioTestFolder(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
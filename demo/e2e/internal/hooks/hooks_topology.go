@@ -0,0 +1,138 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/assets"
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/demo/e2e/internal/scheme"
+	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+	"github.com/filariow/mctest/pkg/infra/namespace"
+	"github.com/filariow/mctest/pkg/topology"
+)
+
+// topology provisioner factories
+var topologyFactories = map[string]topology.ProvisionerFactory{
+	"clusterapi": clusterAPIProvisionerFactory,
+	"namespace":  namespaceProvisionerFactory,
+}
+
+func provisionTopology(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	n, ok := scenarioTopology(sc)
+	if !ok {
+		return ctx, nil
+	}
+
+	d, err := assets.Topologies.ReadFile(path.Join("config", "topology", n+".yaml"))
+	if err != nil {
+		return ctx, fmt.Errorf("error reading topology %s: %w", n, err)
+	}
+
+	t, err := topology.Parse(d)
+	if err != nil {
+		return ctx, err
+	}
+
+	return topology.Provision(ctx, *t, topologyFactories, sc.Id, client.Options{Scheme: scheme.DefaultSchemeHost})
+}
+
+func unprovisionTopology(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+	// if an error occurred before, do not cleanup
+	if err != nil {
+		return ctx, nil
+	}
+
+	if _, err := topology.ProvisionersFromContext(ctx); errors.Is(err, econtext.ErrKeyNotFound) {
+		// no topology for the scenario
+		return ctx, nil
+	}
+
+	lctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := topology.Unprovision(lctx); err != nil {
+		log.Printf("error unprovisioning topology: %v", err)
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+func scenarioTopology(sc *godog.Scenario) (string, bool) {
+	for _, t := range sc.Tags {
+		if n, ok := strings.CutPrefix(t.Name, tagTopologyPrefix); ok {
+			return n, true
+		}
+	}
+	return "", false
+}
+
+// clusterAPIProvisionerFactory builds a ClusterAPI provisioner from the default cluster spec.
+// Overrides are merged into the Cluster resource.
+func clusterAPIProvisionerFactory(ctx context.Context, scenarioId string, spec topology.ClusterSpec) (infra.ClusterProvisioner, error) {
+	k, err := einfra.ManagementClusterFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := einfra.AuxiliaryScenarioNamespaceFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mm, err := k.ParseResources(ctx, assets.DefaultClusterSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make([]unstructured.Unstructured, len(mm))
+	for i, m := range mm {
+		m.SetNamespace(ns)
+		if m.GetKind() == "Cluster" {
+			mergeOverrides(m.Object, spec.Overrides)
+		}
+		scoped[i] = m
+	}
+
+	return clusterapi.NewClusterAPIProvisioner(k, scoped, fmt.Sprintf("%s-%s", spec.Name, scenarioId)), nil
+}
+
+// namespaceProvisionerFactory builds a namespace provisioner in the management cluster
+func namespaceProvisionerFactory(ctx context.Context, scenarioId string, spec topology.ClusterSpec) (infra.ClusterProvisioner, error) {
+	k, err := einfra.ManagementClusterFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	n := fmt.Sprintf("test-%s-%s", spec.Name, scenarioId)
+	return namespace.NewNamespaceProvisioner(k, n, scenarioLabels(scenarioId)), nil
+}
+
+// mergeOverrides recursively merges src into dst. Values that are not maps are replaced.
+func mergeOverrides(dst, src map[string]interface{}) {
+	for k, v := range src {
+		sm, ok := v.(map[string]interface{})
+		if !ok {
+			dst[k] = v
+			continue
+		}
+
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm = map[string]interface{}{}
+			dst[k] = dm
+		}
+		mergeOverrides(dm, sm)
+	}
+}
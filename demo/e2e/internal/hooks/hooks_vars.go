@@ -0,0 +1,57 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cucumber/godog"
+
+	einfra "github.com/filariow/mctest/demo/e2e/internal/infra"
+	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/topology"
+	"github.com/filariow/mctest/pkg/vars"
+)
+
+// injectVariables injects the scenario's variables store into context.
+// It is populated with the following variables:
+//   - scenario.id: the id of the scenario
+//   - scenario.namespace: the scenario namespace, if any
+//   - namespaces.<name>: the additional namespace <name> of the scenario, if any
+//   - clusters.<name>.name: the name of the topology's cluster <name>, if known
+//   - clusters.<name>.namespace: the namespace of the topology's cluster <name>, if namespace-scoped
+func injectVariables(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+	s := vars.NewStore()
+	s.Set("scenario.id", sc.Id)
+
+	if ns, err := einfra.ScenarioNamespaceFromContext(ctx); err == nil {
+		s.Set("scenario.namespace", ns)
+	}
+
+	if nn, err := einfra.ScenarioNamespacesFromContext(ctx); err == nil {
+		for n, ns := range nn {
+			s.Set(vars.Join("namespaces", n), ns)
+		}
+	}
+
+	pp, err := topology.ProvisionersFromContext(ctx)
+	switch {
+	case errors.Is(err, econtext.ErrKeyNotFound):
+		// no topology for the scenario
+	case err != nil:
+		return ctx, err
+	default:
+		for _, p := range pp {
+			if np, ok := p.ClusterProvisioner.(infra.ClusterNamesProvider); ok {
+				if nn := np.ClusterNames(); len(nn) > 0 {
+					s.Set(vars.Join("clusters", p.Name, "name"), nn[0])
+				}
+			}
+			if np, ok := p.ClusterProvisioner.(infra.NamespacedProvisioner); ok {
+				s.Set(vars.Join("clusters", p.Name, "namespace"), np.GetNamespace())
+			}
+		}
+	}
+
+	return vars.StoreIntoContext(ctx, s), nil
+}
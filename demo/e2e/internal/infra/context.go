@@ -4,6 +4,7 @@ import (
 	"context"
 
 	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/faults"
 	pinfra "github.com/filariow/mctest/pkg/infra"
 	"github.com/filariow/mctest/pkg/kube"
 )
@@ -17,6 +18,14 @@ const (
 	// namespaces
 	keyScenarioNamespace          string = "scenario-namespace"
 	keyAuxiliaryScenarioNamespace string = "auxiliary-scenario-namespace"
+	keyScenarioNamespaces         string = "scenario-namespaces"
+	// recorder
+	keyAPIRecorder string = "api-recorder"
+	keyCassette    string = "cassette"
+	// fault injection proxy
+	keyAPIProxy string = "api-proxy"
+	// cluster registrations
+	keyRegistrations string = "registrations"
 )
 
 // provisioners
@@ -81,3 +90,68 @@ func ScenarioNamespaceFromContext(ctx context.Context) (string, error) {
 func ScenarioNamespaceFromContextOrDie(ctx context.Context) string {
 	return econtext.FromContextOrDie[string](ctx, keyScenarioNamespace)
 }
+
+// additional namespaces of the scenario, by name
+func ScenarioNamespacesIntoContext(ctx context.Context, value map[string]string) context.Context {
+	return econtext.IntoContext(ctx, keyScenarioNamespaces, value)
+}
+
+func ScenarioNamespacesFromContext(ctx context.Context) (map[string]string, error) {
+	return econtext.FromContext[map[string]string](ctx, keyScenarioNamespaces)
+}
+
+func ScenarioNamespacesFromContextOrDie(ctx context.Context) map[string]string {
+	return econtext.FromContextOrDie[map[string]string](ctx, keyScenarioNamespaces)
+}
+
+// API calls recorder
+func APIRecorderIntoContext(ctx context.Context, value *kube.Recorder) context.Context {
+	return econtext.IntoContext(ctx, keyAPIRecorder, value)
+}
+
+func APIRecorderFromContext(ctx context.Context) (*kube.Recorder, error) {
+	return econtext.FromContext[*kube.Recorder](ctx, keyAPIRecorder)
+}
+
+func APIRecorderFromContextOrDie(ctx context.Context) *kube.Recorder {
+	return econtext.FromContextOrDie[*kube.Recorder](ctx, keyAPIRecorder)
+}
+
+// cassette recording the scenario's HTTP interactions
+func CassetteIntoContext(ctx context.Context, value *kube.Cassette) context.Context {
+	return econtext.IntoContext(ctx, keyCassette, value)
+}
+
+func CassetteFromContext(ctx context.Context) (*kube.Cassette, error) {
+	return econtext.FromContext[*kube.Cassette](ctx, keyCassette)
+}
+
+func CassetteFromContextOrDie(ctx context.Context) *kube.Cassette {
+	return econtext.FromContextOrDie[*kube.Cassette](ctx, keyCassette)
+}
+
+// fault injection proxy in front of the scenario cluster
+func APIProxyIntoContext(ctx context.Context, value *faults.Proxy) context.Context {
+	return econtext.IntoContext(ctx, keyAPIProxy, value)
+}
+
+func APIProxyFromContext(ctx context.Context) (*faults.Proxy, error) {
+	return econtext.FromContext[*faults.Proxy](ctx, keyAPIProxy)
+}
+
+func APIProxyFromContextOrDie(ctx context.Context) *faults.Proxy {
+	return econtext.FromContextOrDie[*faults.Proxy](ctx, keyAPIProxy)
+}
+
+// clusters registered by the scenario
+func RegistrationsIntoContext(ctx context.Context, value []*pinfra.Registration) context.Context {
+	return econtext.IntoContext(ctx, keyRegistrations, value)
+}
+
+func RegistrationsFromContext(ctx context.Context) ([]*pinfra.Registration, error) {
+	return econtext.FromContext[[]*pinfra.Registration](ctx, keyRegistrations)
+}
+
+func RegistrationsFromContextOrDie(ctx context.Context) []*pinfra.Registration {
+	return econtext.FromContextOrDie[[]*pinfra.Registration](ctx, keyRegistrations)
+}
@@ -0,0 +1,97 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cucumber/godog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/assert"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+	"github.com/filariow/mctest/pkg/vars"
+)
+
+func RegisterStepFuncsAssertions(ctx *godog.ScenarioContext) {
+	ctx.Step(`^Resource satisfies "(.*)":$`, ResourcesSatisfy)
+	ctx.Step(`^Resources satisfy "(.*)":$`, ResourcesSatisfy)
+
+	ctx.Step(`^Resource satisfies JSONPath "(.*)":$`, ResourceSatisfiesJSONPath)
+}
+
+// ResourcesSatisfy checks the CEL expression is satisfied.
+// The first resource is bound as `self`, all of them are bound as lists named after their plural.
+// Resources with no name select all the resources of their kind.
+func ResourcesSatisfy(ctx context.Context, expr, spec string) error {
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu, err := parseSelectors(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	// invalid expressions fail the step without polling
+	nn, err := assert.BindingNames(k, uu)
+	if err != nil {
+		return err
+	}
+	e, err := assert.Compile(expr, nn)
+	if err != nil {
+		return err
+	}
+
+	return poll.DoWithTimeout(ctx, 2*time.Second, 1*time.Minute, func(ctx context.Context) error {
+		b, err := assert.Bind(ctx, k, uu)
+		if err != nil {
+			return err
+		}
+		if err := e.Evaluate(b); err != nil {
+			self := unstructured.Unstructured{Object: b[assert.SelfBinding].(map[string]interface{})}
+			return fmt.Errorf("%w\n%s", err, kube.DescribeDiff(nil, &self, kube.DiffOptions{}))
+		}
+		return nil
+	})
+}
+
+// ResourceSatisfiesJSONPath checks the JSONPath assertion, e.g. `{.status.phase} == Ready`, is satisfied
+func ResourceSatisfiesJSONPath(ctx context.Context, expr, spec string) error {
+	a, err := assert.ParseJSONPathAssertion(expr)
+	if err != nil {
+		return err
+	}
+
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu, err := parseResources(ctx, k, spec)
+	if err != nil {
+		return err
+	}
+
+	return poll.DoWithTimeout(ctx, 2*time.Second, 1*time.Minute, func(ctx context.Context) error {
+		for _, u := range uu {
+			r := unstructured.Unstructured{}
+			r.SetGroupVersionKind(u.GroupVersionKind())
+			t := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+			if err := k.Get(ctx, t, &r, &client.GetOptions{}); err != nil {
+				return err
+			}
+
+			if err := a.Check(r); err != nil {
+				return fmt.Errorf("%w\n%s", err, kube.DescribeDiff(&u, &r, kube.DiffOptions{}))
+			}
+		}
+		return nil
+	})
+}
+
+// parseSelectors parses the resources in spec, allowing them to have no name
+func parseSelectors(ctx context.Context, spec string) ([]unstructured.Unstructured, error) {
+	rs, err := vars.ExpandFromContext(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	return kube.ParseManifestsWithOptions(rs, kube.ParseOptions{AllowMissingName: true})
+}
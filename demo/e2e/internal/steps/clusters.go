@@ -0,0 +1,139 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cucumber/godog"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	econtext "github.com/filariow/mctest/pkg/context"
+	pinfra "github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+	"github.com/filariow/mctest/pkg/topology"
+)
+
+const controlPlaneLabel string = "cluster.x-k8s.io/control-plane"
+
+func RegisterStepFuncsClusters(ctx *godog.ScenarioContext) {
+	ctx.Step(`^Cluster "([\w]+[\w-]*)" has (\d+) workers?$`, ClusterHasWorkers)
+	ctx.Step(`^Cluster "([\w]+[\w-]*)" has (\d+) workers? in pool "([\w]+[\w-]*)"$`, ClusterHasWorkersInPool)
+
+	ctx.Step(`^A worker node of cluster "([\w]+[\w-]*)" fails$`, WorkerNodeFails)
+
+	ctx.Step(`^Cluster "([\w]+[\w-]*)" is registered in cluster "([\w]+[\w-]*)"$`, ClusterIsRegisteredInCluster)
+}
+
+// ClusterIsRegisteredInCluster stores a kubeconfig for the source cluster
+// in the "<source>-kubeconfig" Secret of the target cluster's default namespace.
+// The registration is undone when the scenario ends.
+func ClusterIsRegisteredInCluster(ctx context.Context, source, target string) (context.Context, error) {
+	sk, err := topology.ClusterFromContext(ctx, source)
+	if err != nil {
+		return ctx, err
+	}
+
+	tk, err := topology.ClusterFromContext(ctx, target)
+	if err != nil {
+		return ctx, err
+	}
+
+	opts := pinfra.DefaultRegistrationOptions(fmt.Sprintf("%s-kubeconfig", source), "default")
+	r, err := pinfra.RegisterCluster(ctx, sk, tk, opts)
+	if err != nil {
+		return ctx, err
+	}
+
+	rr, err := infra.RegistrationsFromContext(ctx)
+	if err != nil && !errors.Is(err, econtext.ErrKeyNotFound) {
+		return ctx, err
+	}
+	return infra.RegistrationsIntoContext(ctx, append(rr, r)), nil
+}
+
+func ClusterHasWorkers(ctx context.Context, cluster string, workers int) error {
+	return ClusterHasWorkersInPool(ctx, cluster, workers, "")
+}
+
+func ClusterHasWorkersInPool(ctx context.Context, cluster string, workers int, pool string) error {
+	return onClusterMachines(ctx, cluster, func(m pinfra.MachineManager, name string) error {
+		return m.ScaleWorkers(ctx, name, pool, workers)
+	})
+}
+
+func WorkerNodeFails(ctx context.Context, cluster string) error {
+	r, err := labels.NewRequirement(controlPlaneLabel, selection.DoesNotExist, nil)
+	if err != nil {
+		return err
+	}
+
+	return onClusterMachines(ctx, cluster, func(m pinfra.MachineManager, name string) error {
+		return m.DeleteMachine(ctx, name, labels.NewSelector().Add(*r))
+	})
+}
+
+// onClusterMachines runs f on the provisioner managing the machines of the given cluster.
+// The cluster is first looked up among the scenario's topology clusters, then
+// f is run on the registered provisioners until one of them owns the cluster
+func onClusterMachines(ctx context.Context, cluster string, f func(m pinfra.MachineManager, name string) error) error {
+	m, name, ok, err := topologyClusterMachines(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return f(m, name)
+	}
+
+	pp, err := infra.ProvisionersFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pp {
+		m, ok := p.(pinfra.MachineManager)
+		if !ok {
+			continue
+		}
+
+		if err := f(m, cluster); !errors.Is(err, clusterapi.ErrClusterNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: no provisioner managing machines of cluster %s", clusterapi.ErrClusterNotFound, cluster)
+}
+
+// topologyClusterMachines returns the machine manager of the topology's cluster
+// with the given name, along with the name of the cluster it provisioned
+func topologyClusterMachines(ctx context.Context, cluster string) (pinfra.MachineManager, string, bool, error) {
+	pp, err := topology.ProvisionersFromContext(ctx)
+	switch {
+	case errors.Is(err, econtext.ErrKeyNotFound):
+		// no topology for the scenario
+		return nil, "", false, nil
+	case err != nil:
+		return nil, "", false, err
+	}
+
+	for _, p := range pp {
+		if p.Name != cluster {
+			continue
+		}
+
+		m, ok := p.ClusterProvisioner.(pinfra.MachineManager)
+		if !ok {
+			return nil, "", false, fmt.Errorf("provisioner of topology cluster %s can not manage machines", cluster)
+		}
+
+		name := cluster
+		if np, ok := p.ClusterProvisioner.(pinfra.ClusterNamesProvider); ok {
+			if nn := np.ClusterNames(); len(nn) > 0 {
+				name = nn[0]
+			}
+		}
+		return m, name, true, nil
+	}
+	return nil, "", false, nil
+}
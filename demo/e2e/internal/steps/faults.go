@@ -0,0 +1,104 @@
+package steps
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/faults"
+)
+
+// steps programming the fault injection proxy of scenarios tagged with @api-faults
+func RegisterStepFuncsFaults(ctx *godog.ScenarioContext) {
+	ctx.Step(`^API server responds with status (\d+) to "([\w,]+)" requests on "([\w.,]+)"$`, APIServerRespondsWith)
+	ctx.Step(`^API server responds with status (\d+) to the next (\d+) "([\w,]+)" requests on "([\w.,]+)"$`, APIServerRespondsWithTimes)
+	ctx.Step(`^API server delays "([\w,]+)" requests on "([\w.,]+)" by "([\w.]+)"$`, APIServerDelays)
+	ctx.Step(`^API server drops watches on "([\w.,]+)" after (\d+) events?$`, APIServerDropsWatches)
+	ctx.Step(`^API server faults are cleared$`, APIServerFaultsAreCleared)
+	ctx.Step(`^API server proxy kubeconfig is stored in Secret "([\w]+[\w-]*)"$`, APIServerProxyKubeconfigIsStored)
+}
+
+func APIServerRespondsWith(ctx context.Context, code int, verbs, resources string) error {
+	return APIServerRespondsWithTimes(ctx, code, 0, verbs, resources)
+}
+
+func APIServerRespondsWithTimes(ctx context.Context, code, times int, verbs, resources string) error {
+	return addFault(ctx, faults.Rule{
+		Verbs:      strings.Split(verbs, ","),
+		Resources:  strings.Split(resources, ","),
+		Times:      times,
+		StatusCode: code,
+	})
+}
+
+func APIServerDelays(ctx context.Context, verbs, resources, latency string) error {
+	d, err := time.ParseDuration(latency)
+	if err != nil {
+		return err
+	}
+
+	return addFault(ctx, faults.Rule{
+		Verbs:     strings.Split(verbs, ","),
+		Resources: strings.Split(resources, ","),
+		Latency:   d,
+	})
+}
+
+func APIServerDropsWatches(ctx context.Context, resources string, events int) error {
+	return addFault(ctx, faults.DropWatchAfter(events, strings.Split(resources, ",")...))
+}
+
+func APIServerFaultsAreCleared(ctx context.Context) error {
+	p, err := infra.APIProxyFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.Reset()
+	return nil
+}
+
+// APIServerProxyKubeconfigIsStored stores a kubeconfig for the proxy in a Secret of the scenario namespace,
+// under the key kubeconfig, e.g. for an operator under test to reach the API server through the proxy.
+// Pods reach the proxy only if MCTEST_API_PROXY_HOST is set.
+func APIServerProxyKubeconfigIsStored(ctx context.Context, name string) error {
+	p, err := infra.APIProxyFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	k, err := infra.ScenarioClusterFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	ns, err := infra.ScenarioNamespaceFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	kd, err := p.Kubeconfig(&ns)
+	if err != nil {
+		return err
+	}
+
+	s := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Data:       map[string][]byte{"kubeconfig": kd},
+	}
+	return k.Create(ctx, &s, &client.CreateOptions{})
+}
+
+func addFault(ctx context.Context, r faults.Rule) error {
+	p, err := infra.APIProxyFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	p.AddRule(r)
+	return nil
+}
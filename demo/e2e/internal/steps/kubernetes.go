@@ -10,17 +10,25 @@ import (
 
 	"github.com/cucumber/godog"
 	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/assert"
+	"github.com/filariow/mctest/pkg/kube"
 	"github.com/filariow/mctest/pkg/poll"
 	"github.com/filariow/mctest/pkg/testrun"
+	"github.com/filariow/mctest/pkg/topology"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// field manager used when applying resources
+const fieldManager string = "mctest"
+
 func RegisterStepFuncsKubernetes(ctx *godog.ScenarioContext) {
 	ctx.Step(`^Resource is created:$`, ResourcesAreCreated)
 	ctx.Step(`^Resources are created:$`, ResourcesAreCreated)
+	ctx.Step(`^Resource is created in cluster "([\w]+[\w-]*)":$`, ResourcesAreCreatedInCluster)
+	ctx.Step(`^Resources are created in cluster "([\w]+[\w-]*)":$`, ResourcesAreCreatedInCluster)
 
 	ctx.Step(`^Resource can not be created:$`, ResourcesCanNotBeCreated)
 	ctx.Step(`^Resources can not be created:$`, ResourcesCanNotBeCreated)
@@ -28,9 +36,16 @@ func RegisterStepFuncsKubernetes(ctx *godog.ScenarioContext) {
 	ctx.Step(`^Resource is updated:$`, ResourcesAreUpdated)
 	ctx.Step(`^Resources are updated:$`, ResourcesAreUpdated)
 
+	ctx.Step(`^Resource is applied:$`, ResourcesAreApplied)
+	ctx.Step(`^Resources are applied:$`, ResourcesAreApplied)
+
 	ctx.Step(`^Resource exists:$`, ResourcesExist)
 	ctx.Step(`^Resource exists in scenario namespace:$`, ResourcesExist)
 	ctx.Step(`^Resources exist:$`, ResourcesExist)
+	ctx.Step(`^Resource exists in cluster "([\w]+[\w-]*)":$`, ResourcesExistInCluster)
+	ctx.Step(`^Resources exist in cluster "([\w]+[\w-]*)":$`, ResourcesExistInCluster)
+	ctx.Step(`^Resource exists ignoring list order:$`, ResourcesExistIgnoringListOrder)
+	ctx.Step(`^Resources exist ignoring list order:$`, ResourcesExistIgnoringListOrder)
 
 	ctx.Step(`^Resource doesn't exist:$`, ResourcesNotExist)
 	ctx.Step(`^Resources don't exist:$`, ResourcesNotExist)
@@ -40,13 +55,29 @@ func RegisterStepFuncsKubernetes(ctx *godog.ScenarioContext) {
 }
 
 func ResourcesExist(ctx context.Context, spec string) error {
-	return poll.DoWithTimeout(ctx, time.Second, 10*time.Second, func(ctx context.Context) error {
-		k := infra.ScenarioClusterFromContextOrDie(ctx)
-		uu, err := k.ParseResources(ctx, spec)
-		if err != nil {
-			return err
-		}
+	return resourcesExist(ctx, infra.ScenarioClusterFromContextOrDie(ctx), spec, assert.MatchOptions{})
+}
+
+func ResourcesExistIgnoringListOrder(ctx context.Context, spec string) error {
+	return resourcesExist(ctx, infra.ScenarioClusterFromContextOrDie(ctx), spec, assert.MatchOptions{IgnoreListOrder: true})
+}
+
+func ResourcesExistInCluster(ctx context.Context, cluster, spec string) error {
+	k, err := topology.ClusterFromContext(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	return resourcesExist(ctx, k, spec, assert.MatchOptions{})
+}
+
+// resourcesExist checks the resources exist and every field in spec matches the live objects
+func resourcesExist(ctx context.Context, k kube.Client, spec string, opts assert.MatchOptions) error {
+	uu, err := parseResources(ctx, k, spec)
+	if err != nil {
+		return err
+	}
 
+	return poll.DoWithTimeout(ctx, time.Second, 10*time.Second, func(ctx context.Context) error {
 		for _, u := range uu {
 			lu := u.DeepCopy()
 
@@ -54,6 +85,10 @@ func ResourcesExist(ctx context.Context, spec string) error {
 			if err := k.Get(ctx, t, lu, &client.GetOptions{}); err != nil {
 				return err
 			}
+
+			if err := assert.Match(u, *lu, opts); err != nil {
+				return fmt.Errorf("%w\n%s", err, kube.DescribeDiff(&u, lu, kube.DiffOptions{OnlyExpectedFields: true}))
+			}
 		}
 		return nil
 	})
@@ -64,32 +99,32 @@ func ResourcesNotExist(ctx context.Context, spec string) error {
 	defer cancel()
 
 	k := infra.ScenarioClusterFromContextOrDie(ctx)
-	uu, err := k.ParseResources(ctx, spec)
+	uu, err := parseResources(ctx, k, spec)
 	if err != nil {
 		return err
 	}
 
 	// TODO: use concurrency here
 	for _, u := range uu {
-		lu, err := poll.DoRWithTimeout(ctx, time.Second, 20*time.Second, func(ctx context.Context) (*unstructured.Unstructured, error) {
-			t := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+		// live object found by the last poll, if any
+		var live *unstructured.Unstructured
+		if err := poll.DoWithTimeout(ctx, time.Second, 20*time.Second, func(ctx context.Context) error {
+			live = nil
 			lu := u.DeepCopy()
+			t := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
 			if err := k.Get(ctx, t, lu, &client.GetOptions{}); err != nil {
 				if kerrors.IsNotFound(err) {
-					return nil, nil
+					return nil
 				}
+				return err
 			}
-			return nil, err
-		})
-
-		if err != nil {
-			ld, err := lu.MarshalJSON()
-			if err != nil {
-				return fmt.Errorf(
-					"expected resource not to exists. Found: [ ApiVersion=%s, Kind=%s, Namespace=%s, Name=%s ]. Error marshaling as json: %w",
-					lu.GetAPIVersion(), lu.GetKind(), lu.GetNamespace(), lu.GetName(), err)
+			live = lu
+			return fmt.Errorf("resource %s %s/%s still exists", lu.GetKind(), lu.GetNamespace(), lu.GetName())
+		}); err != nil {
+			if live == nil {
+				return fmt.Errorf("expected resource not to exist: %w", err)
 			}
-			return fmt.Errorf("expected resource not to exists. Found: %s", ld)
+			return fmt.Errorf("expected resource not to exist: %w\n%s", err, kube.DescribeDiff(nil, live, kube.DiffOptions{}))
 		}
 	}
 
@@ -101,7 +136,7 @@ func ResourcesAreUpdated(ctx context.Context, spec string) error {
 	defer cancel()
 
 	k := infra.ScenarioClusterFromContextOrDie(ctx)
-	uu, err := k.ParseResources(ctx, spec)
+	uu, err := parseResources(ctx, k, spec)
 	if err != nil {
 		return err
 	}
@@ -122,13 +157,37 @@ func ResourcesAreUpdated(ctx context.Context, spec string) error {
 	return nil
 }
 
+func ResourcesAreApplied(ctx context.Context, spec string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu, err := parseResources(ctx, k, spec)
+	if err != nil {
+		return err
+	}
+
+	// ownership is forced, so conflicts with other field managers do not fail the step
+	return poll.DoWithTimeout(ctx, 2*time.Second, 10*time.Second, func(ctx context.Context) error {
+		_, err := k.Apply(ctx, uu, fieldManager, true)
+		return err
+	})
+}
+
 func ResourcesAreCreated(ctx context.Context, spec string) error {
-	return resourcesAreCreated(ctx, spec, nil)
+	return resourcesAreCreated(ctx, infra.ScenarioClusterFromContextOrDie(ctx), spec, nil)
 }
 
-func resourcesAreCreated(ctx context.Context, spec string, namespace *string) error {
-	k := infra.ScenarioClusterFromContextOrDie(ctx)
-	uu, err := k.ParseResources(ctx, spec)
+func ResourcesAreCreatedInCluster(ctx context.Context, cluster, spec string) error {
+	k, err := topology.ClusterFromContext(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	return resourcesAreCreated(ctx, k, spec, nil)
+}
+
+func resourcesAreCreated(ctx context.Context, k kube.Client, spec string, namespace *string) error {
+	uu, err := parseResources(ctx, k, spec)
 	if err != nil {
 		return err
 	}
@@ -150,7 +209,7 @@ func resourcesAreCreated(ctx context.Context, spec string, namespace *string) er
 
 func ResourcesCanNotBeCreated(ctx context.Context, spec string) error {
 	k := infra.ScenarioClusterFromContextOrDie(ctx)
-	uu, err := k.ParseResources(ctx, spec)
+	uu, err := parseResources(ctx, k, spec)
 	if err != nil {
 		return err
 	}
@@ -158,65 +217,51 @@ func ResourcesCanNotBeCreated(ctx context.Context, spec string) error {
 	for _, u := range uu {
 		lu := u.DeepCopy()
 		if err := k.Create(ctx, lu, &client.CreateOptions{}); err == nil {
-			ld, err := lu.MarshalJSON()
-			if err != nil {
-				return fmt.Errorf(
-					"expected resource not to be created. Created: [ ApiVersion=%s, Kind=%s, Namespace=%s, Name=%s ]. Error marshaling as json: %w",
-					lu.GetAPIVersion(), lu.GetKind(), lu.GetNamespace(), lu.GetName(), err)
-			}
-			return fmt.Errorf("expected resource not to be created. Created: %s", ld)
+			return fmt.Errorf("expected resource not to be created, created:\n%s", kube.DescribeDiff(&u, lu, kube.DiffOptions{}))
 		}
 	}
 	return nil
 }
 
 func DeployOperator(ctx context.Context, operator string) error {
-	tf, err := testrun.TestFolderFromContext(ctx)
-	if err != nil {
-		return errors.Join(testrun.ErrTestFolderNotFound, err)
-	}
-
-	// read deployment manifests
-	applyFile := func(fname string) error {
-		opd := path.Join(tf, "config", "default", fname)
-		op, err := os.ReadFile(opd)
-		if err != nil {
-			return err
-		}
-
-		// Apply deployment resources
-		return ResourcesAreCreated(ctx, string(op))
-	}
+	return deployOperator(ctx, operator, nil)
+}
 
-	if err := applyFile(fmt.Sprintf("%s-rbac.yaml", operator)); err != nil {
-		return err
+func DeployOperatorInNamespace(ctx context.Context, operator, namespace string) error {
+	if err := deployOperator(ctx, operator, &namespace); err != nil {
+		return fmt.Errorf("%w: deploying operator %s in namespace %s", err, operator, namespace)
 	}
-	return applyFile(fmt.Sprintf("%s.yaml", operator))
+	return nil
 }
 
-func DeployOperatorInNamespace(ctx context.Context, operator, namespace string) error {
+func deployOperator(ctx context.Context, operator string, namespace *string) error {
 	tf, err := testrun.TestFolderFromContext(ctx)
 	if err != nil {
 		return errors.Join(testrun.ErrTestFolderNotFound, err)
 	}
 
 	// read deployment manifests
-	applyFile := func(fname string) error {
-		opd := path.Join(tf, "config", "default", fname)
-		op, err := os.ReadFile(opd)
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu := []unstructured.Unstructured{}
+	for _, fname := range []string{fmt.Sprintf("%s-rbac.yaml", operator), fmt.Sprintf("%s.yaml", operator)} {
+		op, err := os.ReadFile(path.Join(tf, "config", "default", fname))
 		if err != nil {
 			return err
 		}
 
-		// Apply deployment resources
-		if err := resourcesAreCreated(ctx, string(op), &namespace); err != nil {
-			return fmt.Errorf("%w: creating resource in namespace %s: %s ", err, namespace, string(op))
+		fuu, err := k.ParseResources(ctx, string(op))
+		if err != nil {
+			return err
 		}
-		return nil
+		uu = append(uu, fuu...)
 	}
 
-	if err := applyFile(fmt.Sprintf("%s-rbac.yaml", operator)); err != nil {
-		return err
+	if namespace != nil {
+		for _, u := range uu {
+			u.SetNamespace(*namespace)
+		}
 	}
-	return applyFile(fmt.Sprintf("%s.yaml", operator))
+
+	// apply deployment resources in dependency order
+	return k.ApplyBundle(ctx, uu, fieldManager)
 }
@@ -0,0 +1,93 @@
+package steps_test
+
+import (
+	"context"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/demo/e2e/internal/steps"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// if set to true, cassettes are recorded against the cluster of the current kubeconfig
+const envRecordCassettes = "MCTEST_RECORD_CASSETTES"
+
+// cassetteCluster returns a scenario cluster replaying the interactions of the named cassette in testdata
+func cassetteCluster(t *testing.T, name string) context.Context {
+	t.Helper()
+
+	cp := path.Join("testdata", name+".yaml")
+	cfg := &rest.Config{Host: "https://cassette.invalid"}
+	mode := kube.CassetteReplay
+	if r, _ := strconv.ParseBool(os.Getenv(envRecordCassettes)); r {
+		var err error
+		if cfg, err = kube.GetRESTConfig(); err != nil {
+			t.Fatal(err)
+		}
+		mode = kube.CassetteRecord
+	}
+
+	c, err := kube.NewCassette(cp, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := c.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	k, err := kube.New(c.WrapConfig(cfg), client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return infra.ScenarioClusterIntoContext(context.Background(), k)
+}
+
+func Test_ResourceSteps(t *testing.T) {
+	ctx := cassetteCluster(t, "resource-steps")
+
+	cm := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: mctest-cassette
+  namespace: default
+data:
+  key: value
+`
+	if err := steps.ResourcesAreCreated(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := steps.ResourcesExist(ctx, cm); err != nil {
+		t.Error(err)
+	}
+	if err := steps.ResourcesNotExist(ctx, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: mctest-cassette-missing
+  namespace: default
+`); err != nil {
+		t.Error(err)
+	}
+	if err := steps.ResourcesCanNotBeCreated(ctx, cm); err != nil {
+		t.Error(err)
+	}
+
+	// leave the cluster as it was found when recording
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu, err := k.ParseResources(ctx, cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Delete(ctx, &uu[0]); err != nil {
+		t.Error(err)
+	}
+}
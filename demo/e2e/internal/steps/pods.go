@@ -0,0 +1,75 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+func RegisterStepFuncsPods(ctx *godog.ScenarioContext) {
+	ctx.Step(`^Pod "([\w.-]+)" logs a line matching "(.*)"$`, PodLogsLine)
+	ctx.Step(`^Container "([\w.-]+)" of pod "([\w.-]+)" logs a line matching "(.*)"$`, ContainerLogsLine)
+
+	ctx.Step(`^Command "([^"]+)" succeeds in pod "([\w.-]+)"$`, CommandSucceedsInPod)
+	ctx.Step(`^Command "([^"]+)" in pod "([\w.-]+)" outputs:$`, CommandInPodOutputs)
+}
+
+func PodLogsLine(ctx context.Context, pod, expr string) error {
+	return ContainerLogsLine(ctx, "", pod, expr)
+}
+
+func ContainerLogsLine(ctx context.Context, container, pod, expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	_, err = k.WaitForLogLine(ctx, client.ObjectKey{Name: pod}, container, re)
+	return err
+}
+
+func CommandSucceedsInPod(ctx context.Context, cmd, pod string) error {
+	_, err := execInPod(ctx, cmd, pod)
+	return err
+}
+
+func CommandInPodOutputs(ctx context.Context, cmd, pod, expected string) error {
+	return poll.DoWithTimeout(ctx, 2*time.Second, 30*time.Second, func(ctx context.Context) error {
+		o, err := execInPod(ctx, cmd, pod)
+		if err != nil {
+			return err
+		}
+
+		if strings.TrimSpace(o) != strings.TrimSpace(expected) {
+			return fmt.Errorf("expected command %q to output:\n%s\ngot:\n%s", cmd, expected, o)
+		}
+		return nil
+	})
+}
+
+// execInPod runs cmd in the first container of pod and returns its standard output.
+// cmd is split on whitespace with strings.Fields and run without a shell, so quotes, pipes and variables
+// are not interpreted, e.g. `sh -c 'echo $HOME'` runs sh with the arguments -c, 'echo and $HOME'.
+func execInPod(ctx context.Context, cmd, pod string) (string, error) {
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	r, err := k.Exec(ctx, client.ObjectKey{Name: pod}, "", strings.Fields(cmd))
+	if err != nil {
+		if r != nil {
+			return "", fmt.Errorf("%w\nstderr:\n%s", err, r.Stderr)
+		}
+		return "", err
+	}
+	return string(r.Stdout), nil
+}
@@ -4,4 +4,9 @@ import "github.com/cucumber/godog"
 
 func InjectSteps(ctx *godog.ScenarioContext) {
 	RegisterStepFuncsKubernetes(ctx)
+	RegisterStepFuncsClusters(ctx)
+	RegisterStepFuncsVariables(ctx)
+	RegisterStepFuncsAssertions(ctx)
+	RegisterStepFuncsFaults(ctx)
+	RegisterStepFuncsPods(ctx)
 }
@@ -0,0 +1,105 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/demo/e2e/internal/infra"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+	"github.com/filariow/mctest/pkg/vars"
+)
+
+func RegisterStepFuncsVariables(ctx *godog.ScenarioContext) {
+	ctx.Step(`^Variable "([\w.-]+)" is set to "([^"]*)"$`, VariableIsSet)
+	ctx.Step(`^Field "([^"]+)" of resource is stored in variable "([\w.-]+)":$`, FieldIsStoredInVariable)
+}
+
+func VariableIsSet(ctx context.Context, name, value string) error {
+	s, err := vars.StoreFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	v, err := s.Expand(value)
+	if err != nil {
+		return err
+	}
+	s.Set(name, v)
+	return nil
+}
+
+// FieldIsStoredInVariable captures the value at the given JSONPath of the resource
+// into a variable, so that following steps can refer to it as ${name}
+func FieldIsStoredInVariable(ctx context.Context, path, name, spec string) error {
+	s, err := vars.StoreFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	k := infra.ScenarioClusterFromContextOrDie(ctx)
+	uu, err := parseResources(ctx, k, spec)
+	if err != nil {
+		return err
+	}
+	if len(uu) != 1 {
+		return fmt.Errorf("expected exactly one resource, found %d", len(uu))
+	}
+
+	jp := jsonpath.New(name)
+	if !strings.HasPrefix(path, "{") {
+		path = fmt.Sprintf("{%s}", path)
+	}
+	if err := jp.Parse(path); err != nil {
+		return err
+	}
+
+	u := uu[0]
+	v, err := poll.DoRWithTimeout(ctx, time.Second, 30*time.Second, func(ctx context.Context) (*string, error) {
+		r := unstructured.Unstructured{}
+		r.SetGroupVersionKind(u.GroupVersionKind())
+		t := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
+		if err := k.Get(ctx, t, &r, &client.GetOptions{}); err != nil {
+			return nil, err
+		}
+
+		b := bytes.Buffer{}
+		if err := jp.Execute(&b, r.Object); err != nil {
+			return nil, err
+		}
+		v := b.String()
+		return &v, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error capturing %s of %s %s/%s: %w", path, u.GetKind(), u.GetNamespace(), u.GetName(), err)
+	}
+
+	s.Set(name, *v)
+	return nil
+}
+
+// parseResources expands the scenario's variables in the step's spec and parses its resources.
+// Manifests read from files are parsed with k.ParseResources as they are.
+func parseResources(ctx context.Context, k kube.Client, spec string) ([]unstructured.Unstructured, error) {
+	rs, err := vars.ExpandFromContext(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	uu, err := k.ParseResources(ctx, rs)
+	if err != nil {
+		log.Printf("error parsing manifest: %v\nrendered manifest:\n%s", err, rs)
+		return nil, err
+	}
+	return uu, nil
+}
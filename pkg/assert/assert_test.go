@@ -0,0 +1,127 @@
+package assert_test
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/filariow/mctest/pkg/assert"
+)
+
+func deployment(replicas, readyReplicas int64) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+		"spec":       map[string]interface{}{"replicas": replicas, "performer": "john"},
+		"status":     map[string]interface{}{"readyReplicas": readyReplicas, "phase": "Running"},
+	}}
+}
+
+func Test_Evaluate(t *testing.T) {
+	performers := []interface{}{
+		map[string]interface{}{"metadata": map[string]interface{}{"name": "john"}},
+		map[string]interface{}{"metadata": map[string]interface{}{"name": "jane"}},
+	}
+
+	tt := map[string]struct {
+		expr     string
+		object   unstructured.Unstructured
+		expected error
+	}{
+		"top-level fields are bound": {"status.readyReplicas == spec.replicas", deployment(3, 3), nil},
+		"self is bound":              {"self.metadata.name.startsWith('a')", deployment(3, 3), nil},
+		"false expression":           {"status.readyReplicas == spec.replicas", deployment(3, 1), assert.ErrAssertionFailed},
+		"cross-object bindings":      {"performers.exists(p, p.metadata.name == spec.performer)", deployment(1, 1), nil},
+		"not a bool expression":      {"spec.replicas + 1", deployment(1, 1), assert.ErrInvalidExpression},
+		"syntax error":               {"spec.replicas ==", deployment(1, 1), assert.ErrInvalidExpression},
+		"undeclared reference":       {"unknown.field == 1", deployment(1, 1), assert.ErrInvalidExpression},
+	}
+
+	for n, tc := range tt {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			b := assert.BindSelf(tc.object)
+			b["performers"] = performers
+
+			err := assert.Evaluate(tc.expr, b)
+			switch {
+			case tc.expected == nil && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tc.expected != nil && !errors.Is(err, tc.expected):
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	t.Run("missing keys are reported", func(t *testing.T) {
+		t.Parallel()
+
+		err := assert.Evaluate("status.missing == 1", assert.BindSelf(deployment(1, 1)))
+		if err == nil || errors.Is(err, assert.ErrAssertionFailed) {
+			t.Errorf("expected an evaluation error, got %v", err)
+		}
+	})
+}
+
+func Test_Compile(t *testing.T) {
+	nn := []string{"self", "spec", "status", "performers"}
+
+	e, err := assert.Compile("status.readyReplicas == spec.replicas", nn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a compiled expression can be evaluated many times
+	for _, r := range []int64{1, 3} {
+		err := e.Evaluate(assert.BindSelf(deployment(3, r)))
+		if r == 3 && err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		if r != 3 && !errors.Is(err, assert.ErrAssertionFailed) {
+			t.Errorf("expected ErrAssertionFailed, got %v", err)
+		}
+	}
+
+	for _, expr := range []string{"spec.replicas ==", "deployments.size() == 1", "spec.replicas + 1"} {
+		if _, err := assert.Compile(expr, nn); !errors.Is(err, assert.ErrInvalidExpression) {
+			t.Errorf("expected ErrInvalidExpression compiling %s, got %v", expr, err)
+		}
+	}
+}
+
+func Test_JSONPathAssertion(t *testing.T) {
+	tt := map[string]struct {
+		assertion string
+		expected  error
+	}{
+		"string equality":     {"{.status.phase} == Running", nil},
+		"quoted value":        {`.status.phase == "Running"`, nil},
+		"string inequality":   {".status.phase != Pending", nil},
+		"numeric comparison":  {".status.readyReplicas >= 2", nil},
+		"failed comparison":   {".status.readyReplicas < 2", assert.ErrAssertionFailed},
+		"ordering of strings": {".status.phase > Pending", assert.ErrInvalidExpression},
+		"missing operator":    {".status.phase Running", assert.ErrInvalidExpression},
+	}
+
+	for n, tc := range tt {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := assert.ParseJSONPathAssertion(tc.assertion)
+			if err == nil {
+				err = a.Check(deployment(3, 3))
+			}
+
+			switch {
+			case tc.expected == nil && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tc.expected != nil && !errors.Is(err, tc.expected):
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
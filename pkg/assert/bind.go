@@ -0,0 +1,101 @@
+package assert
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// Bind fetches the given resources and binds them for the evaluation of expressions.
+//
+// The first resource is bound as `self`, see BindSelf.
+// Resources with a name are fetched, the ones with no name select all the resources
+// of their kind in their namespace. All of them are bound as lists named after
+// the resource's plural, e.g. `performers`.
+func Bind(ctx context.Context, k kube.Client, objs []unstructured.Unstructured) (Bindings, error) {
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("%w: no resources to bind", ErrInvalidExpression)
+	}
+
+	b := Bindings{}
+	for i, o := range objs {
+		uu, err := fetch(ctx, k, o)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			if len(uu) != 1 {
+				return nil, fmt.Errorf("%w: the first resource must select exactly one resource, found %d", ErrInvalidExpression, len(uu))
+			}
+			for n, v := range BindSelf(uu[0]) {
+				b[n] = v
+			}
+		}
+
+		n, err := resourceBinding(k, o)
+		if err != nil {
+			return nil, err
+		}
+
+		ll, _ := b[n].([]interface{})
+		for _, u := range uu {
+			ll = append(ll, u.Object)
+		}
+		b[n] = ll
+	}
+	return b, nil
+}
+
+// BindingNames returns the names Bind binds the given resources to,
+// so that expressions can be compiled before the resources are fetched
+func BindingNames(k kube.Client, objs []unstructured.Unstructured) ([]string, error) {
+	nn := append([]string{SelfBinding}, selfFields...)
+	for _, o := range objs {
+		n, err := resourceBinding(k, o)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(nn, n) {
+			nn = append(nn, n)
+		}
+	}
+	return nn, nil
+}
+
+// resourceBinding returns the name of the list the resource is bound to, i.e. its plural
+func resourceBinding(k kube.Client, o unstructured.Unstructured) (string, error) {
+	gvk := o.GroupVersionKind()
+	rm, err := k.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", err
+	}
+	return rm.Resource.Resource, nil
+}
+
+func fetch(ctx context.Context, k kube.Client, o unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	if o.GetName() != "" {
+		u := unstructured.Unstructured{}
+		u.SetGroupVersionKind(o.GroupVersionKind())
+		t := types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}
+		if err := k.Get(ctx, t, &u, &client.GetOptions{}); err != nil {
+			return nil, err
+		}
+		return []unstructured.Unstructured{u}, nil
+	}
+
+	ll := unstructured.UnstructuredList{}
+	gvk := o.GroupVersionKind()
+	gvk.Kind += "List"
+	ll.SetGroupVersionKind(gvk)
+	if err := k.List(ctx, &ll, client.InNamespace(o.GetNamespace())); err != nil {
+		return nil, err
+	}
+	return ll.Items, nil
+}
@@ -0,0 +1,134 @@
+package assert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var (
+	ErrAssertionFailed   error = fmt.Errorf("error assertion failed")
+	ErrInvalidExpression error = fmt.Errorf("error invalid expression")
+)
+
+// name of the binding for the asserted object
+const SelfBinding string = "self"
+
+// top-level fields of the asserted object that are bound as variables,
+// so that expressions like `status.replicas == spec.replicas` can be written
+var selfFields = []string{"apiVersion", "kind", "metadata", "spec", "status", "data"}
+
+// Bindings are the variables available to expressions, e.g. `self` or
+// lists of related resources like `performers`
+type Bindings map[string]interface{}
+
+// BindSelf returns the bindings for the given object: `self` and its top-level fields.
+// Missing top-level fields are bound to an empty object.
+func BindSelf(u unstructured.Unstructured) Bindings {
+	b := Bindings{SelfBinding: u.Object}
+	for _, f := range selfFields {
+		v, ok := u.Object[f]
+		if !ok {
+			v = map[string]interface{}{}
+		}
+		b[f] = v
+	}
+	return b
+}
+
+// Expression is a compiled CEL expression
+type Expression struct {
+	expr string
+	prg  cel.Program
+}
+
+// Compile compiles and validates the CEL expression for the given binding names,
+// see BindingNames. It returns an error wrapping ErrInvalidExpression if expr
+// is not a valid boolean expression.
+func Compile(expr string, names []string) (*Expression, error) {
+	oo := []cel.EnvOption{ext.Strings(), ext.Sets()}
+	for _, n := range names {
+		oo = append(oo, cel.Variable(n, cel.DynType))
+	}
+
+	env, err := cel.NewEnv(oo...)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidExpression, expr, iss.Err())
+	}
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("%w: %s: expected a bool expression, found %s", ErrInvalidExpression, expr, t)
+	}
+
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidExpression, expr, err)
+	}
+	return &Expression{expr: expr, prg: prg}, nil
+}
+
+// Evaluate evaluates the expression with the given bindings.
+// It returns nil if the expression evaluates to true, an error wrapping
+// ErrAssertionFailed if it evaluates to false, ErrInvalidExpression if it does not
+// evaluate to a bool.
+func (e *Expression) Evaluate(bindings Bindings) error {
+	out, _, err := e.prg.Eval(map[string]interface{}(bindings))
+	if err != nil {
+		return fmt.Errorf("error evaluating %s (bound variables: %s): %w", e.expr, bindings, err)
+	}
+
+	r, ok := out.Value().(bool)
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: %s: evaluated to %v (%s), expected a bool", ErrInvalidExpression, e.expr, out.Value(), out.Type())
+	case !r:
+		return fmt.Errorf("%w: %s (bound variables: %s)", ErrAssertionFailed, e.expr, bindings)
+	default:
+		return nil
+	}
+}
+
+// Evaluate compiles and evaluates the CEL expression with the given bindings, see Compile
+// and Expression.Evaluate.
+func Evaluate(expr string, bindings Bindings) error {
+	e, err := Compile(expr, bindings.names())
+	if err != nil {
+		return err
+	}
+	return e.Evaluate(bindings)
+}
+
+func (b Bindings) names() []string {
+	nn := make([]string, 0, len(b))
+	for n := range b {
+		nn = append(nn, n)
+	}
+	sort.Strings(nn)
+	return nn
+}
+
+// String summarizes the bindings, e.g. `self=Deployment default/app, performers=[3 items]`
+func (b Bindings) String() string {
+	ss := []string{}
+	for _, n := range b.names() {
+		switch v := b[n].(type) {
+		case map[string]interface{}:
+			if n != SelfBinding {
+				continue
+			}
+			u := unstructured.Unstructured{Object: v}
+			ss = append(ss, fmt.Sprintf("%s=%s %s/%s", n, u.GetKind(), u.GetNamespace(), u.GetName()))
+		case []interface{}:
+			ss = append(ss, fmt.Sprintf("%s=[%d items]", n, len(v)))
+		}
+	}
+	return strings.Join(ss, ", ")
+}
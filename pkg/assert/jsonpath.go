@@ -0,0 +1,92 @@
+package assert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// supported comparison operators, longest first so that `<=` is not parsed as `<`
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// JSONPathAssertion compares the value at a JSONPath of an object with an expected one
+type JSONPathAssertion struct {
+	Path     string
+	Operator string
+	Value    string
+}
+
+// ParseJSONPathAssertion parses assertions in the format `<jsonpath> <operator> <value>`,
+// e.g. `{.status.phase} == Ready` or `.status.replicas >= 3`.
+func ParseJSONPathAssertion(a string) (*JSONPathAssertion, error) {
+	for _, op := range operators {
+		p, v, ok := strings.Cut(a, " "+op+" ")
+		if !ok {
+			continue
+		}
+
+		return &JSONPathAssertion{
+			Path:     strings.TrimSpace(p),
+			Operator: op,
+			Value:    strings.Trim(strings.TrimSpace(v), `"'`),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s: expected format is '<jsonpath> <operator> <value>' with operator one of %v", ErrInvalidExpression, a, operators)
+}
+
+// Check evaluates the assertion against the object.
+// Values are compared as numbers if both of them are numbers, as strings otherwise.
+// Ordering operators are only supported for numbers.
+func (a JSONPathAssertion) Check(u unstructured.Unstructured) error {
+	v, err := kube.EvaluateJSONPath(u, a.Path)
+	if err != nil {
+		return err
+	}
+
+	ok, err := compare(v, a.Operator, a.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidExpression, a, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s on %s %s/%s: actual value is '%s'", ErrAssertionFailed, a, u.GetKind(), u.GetNamespace(), u.GetName(), v)
+	}
+	return nil
+}
+
+func (a JSONPathAssertion) String() string {
+	return fmt.Sprintf("%s %s %s", a.Path, a.Operator, a.Value)
+}
+
+func compare(actual, operator, expected string) (bool, error) {
+	af, aerr := strconv.ParseFloat(actual, 64)
+	ef, eerr := strconv.ParseFloat(expected, 64)
+	if aerr == nil && eerr == nil {
+		switch operator {
+		case "==":
+			return af == ef, nil
+		case "!=":
+			return af != ef, nil
+		case "<":
+			return af < ef, nil
+		case "<=":
+			return af <= ef, nil
+		case ">":
+			return af > ef, nil
+		case ">=":
+			return af >= ef, nil
+		}
+	}
+
+	switch operator {
+	case "==":
+		return actual == expected, nil
+	case "!=":
+		return actual != expected, nil
+	default:
+		return false, fmt.Errorf("operator %s requires numbers, found '%s' and '%s'", operator, actual, expected)
+	}
+}
@@ -0,0 +1,186 @@
+package assert
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var ErrMismatch error = fmt.Errorf("error resource does not match")
+
+// MatchOptions tunes the comparison performed by Match
+type MatchOptions struct {
+	// If true, every item of an expected list has to match a distinct item of the actual list,
+	// in any order and ignoring the actual list's additional items.
+	// Otherwise lists need to have the same length and their items are compared by position.
+	IgnoreListOrder bool
+}
+
+// Mismatch is a field of the expected object that does not match the actual one
+type Mismatch struct {
+	// Path of the field, e.g. `status.conditions[0].status`
+	Path     string
+	Expected interface{}
+	// Actual value, nil if the field is missing
+	Actual interface{}
+	// The field is missing in the actual object
+	Missing bool
+	// The expected list item does not match any item of the actual list
+	Unmatched bool
+}
+
+func (m Mismatch) String() string {
+	if m.Unmatched {
+		return fmt.Sprintf("%s: expected %v, no matching item found", m.Path, format(m.Expected))
+	}
+	if m.Missing {
+		return fmt.Sprintf("%s: expected %v, field is missing", m.Path, format(m.Expected))
+	}
+	return fmt.Sprintf("%s: expected %v, found %v", m.Path, format(m.Expected), format(m.Actual))
+}
+
+// MatchError reports the fields that do not match
+type MatchError struct {
+	Object     unstructured.Unstructured
+	Mismatches []Mismatch
+}
+
+func (e *MatchError) Error() string {
+	ss := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		ss[i] = "  " + m.String()
+	}
+	return fmt.Sprintf("%s %s %s/%s does not match:\n%s",
+		ErrMismatch, e.Object.GetKind(), e.Object.GetNamespace(), e.Object.GetName(), strings.Join(ss, "\n"))
+}
+
+func (e *MatchError) Unwrap() error {
+	return ErrMismatch
+}
+
+// Match checks every field of expected has the same value in actual, i.e. expected is a subset of actual.
+// It returns a *MatchError listing the mismatching fields, if any.
+func Match(expected, actual unstructured.Unstructured, opts MatchOptions) error {
+	mm := matchValue("", expected.Object, actual.Object, opts)
+	if len(mm) == 0 {
+		return nil
+	}
+	return &MatchError{Object: actual, Mismatches: mm}
+}
+
+func matchValue(path string, expected, actual interface{}, opts MatchOptions) []Mismatch {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return []Mismatch{{Path: path, Expected: expected, Actual: actual}}
+		}
+		return matchMap(path, e, a, opts)
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return []Mismatch{{Path: path, Expected: expected, Actual: actual}}
+		}
+		if opts.IgnoreListOrder {
+			return matchListUnordered(path, e, a, opts)
+		}
+		return matchList(path, e, a, opts)
+	default:
+		if !equalScalars(expected, actual) {
+			return []Mismatch{{Path: path, Expected: expected, Actual: actual}}
+		}
+		return nil
+	}
+}
+
+func matchMap(path string, expected, actual map[string]interface{}, opts MatchOptions) []Mismatch {
+	mm := []Mismatch{}
+	for _, k := range sortedKeys(expected) {
+		p := k
+		if path != "" {
+			p = path + "." + k
+		}
+
+		a, ok := actual[k]
+		if !ok {
+			mm = append(mm, Mismatch{Path: p, Expected: expected[k], Missing: true})
+			continue
+		}
+		mm = append(mm, matchValue(p, expected[k], a, opts)...)
+	}
+	return mm
+}
+
+func matchList(path string, expected, actual []interface{}, opts MatchOptions) []Mismatch {
+	if len(expected) != len(actual) {
+		return []Mismatch{{Path: path + " (length)", Expected: len(expected), Actual: len(actual)}}
+	}
+
+	mm := []Mismatch{}
+	for i := range expected {
+		mm = append(mm, matchValue(fmt.Sprintf("%s[%d]", path, i), expected[i], actual[i], opts)...)
+	}
+	return mm
+}
+
+func matchListUnordered(path string, expected, actual []interface{}, opts MatchOptions) []Mismatch {
+	used := make([]bool, len(actual))
+	mm := []Mismatch{}
+	for i, e := range expected {
+		found := false
+		for j, a := range actual {
+			if used[j] || len(matchValue("", e, a, opts)) > 0 {
+				continue
+			}
+			used[j], found = true, true
+			break
+		}
+
+		if !found {
+			mm = append(mm, Mismatch{Path: fmt.Sprintf("%s[%d]", path, i), Expected: e, Unmatched: true})
+		}
+	}
+	return mm
+}
+
+// equalScalars compares scalars, numbers are compared by value regardless of their type
+func equalScalars(expected, actual interface{}) bool {
+	ef, eok := toFloat(expected)
+	af, aok := toFloat(actual)
+	if eok && aok {
+		return ef == af
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func format(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	kk := make([]string, 0, len(m))
+	for k := range m {
+		kk = append(kk, k)
+	}
+	sort.Strings(kk)
+	return kk
+}
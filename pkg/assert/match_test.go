@@ -0,0 +1,105 @@
+package assert_test
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/filariow/mctest/pkg/assert"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func parseOne(t *testing.T, spec string) unstructured.Unstructured {
+	t.Helper()
+
+	uu, err := kube.ParseManifests(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uu[0]
+}
+
+func Test_Match(t *testing.T) {
+	actual := `
+apiVersion: demo.mctest.io/v1alpha1
+kind: Show
+metadata:
+  name: show
+  labels:
+    app: demo
+spec:
+  replicas: 2
+  performers: [john, jane]
+status:
+  state: Open
+  conditions:
+  - type: Ready
+    status: "True"
+  - type: Degraded
+    status: "False"
+`
+
+	tt := map[string]struct {
+		expected   string
+		opts       assert.MatchOptions
+		mismatches []string
+	}{
+		"subset matches": {
+			expected: "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nspec:\n  replicas: 2",
+		},
+		"different scalar": {
+			expected:   "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nstatus:\n  state: Complete",
+			mismatches: []string{`status.state: expected "Complete", found "Open"`},
+		},
+		"missing field": {
+			expected:   "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\n  labels:\n    tier: web",
+			mismatches: []string{`metadata.labels.tier: expected "web", field is missing`},
+		},
+		"list order matters by default": {
+			expected:   "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nspec:\n  performers: [jane, john]",
+			mismatches: []string{`spec.performers[0]: expected "jane", found "john"`, `spec.performers[1]: expected "john", found "jane"`},
+		},
+		"list length matters by default": {
+			expected:   "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nspec:\n  performers: [john]",
+			mismatches: []string{`spec.performers (length): expected 1, found 2`},
+		},
+		"list order ignored": {
+			expected: "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nstatus:\n  conditions:\n  - type: Degraded\n    status: \"False\"",
+			opts:     assert.MatchOptions{IgnoreListOrder: true},
+		},
+		"list item not found": {
+			expected:   "apiVersion: demo.mctest.io/v1alpha1\nkind: Show\nmetadata:\n  name: show\nstatus:\n  conditions:\n  - type: Ready\n    status: \"False\"",
+			opts:       assert.MatchOptions{IgnoreListOrder: true},
+			mismatches: []string{`status.conditions[0]: expected map[status:False type:Ready], no matching item found`},
+		},
+	}
+
+	for n, tc := range tt {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			err := assert.Match(parseOne(t, tc.expected), parseOne(t, actual), tc.opts)
+			if len(tc.mismatches) == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			me := &assert.MatchError{}
+			if !errors.As(err, &me) || !errors.Is(err, assert.ErrMismatch) {
+				t.Fatalf("expected MatchError, got %v", err)
+			}
+			if len(me.Mismatches) != len(tc.mismatches) {
+				t.Fatalf("expected mismatches %v, got %v", tc.mismatches, me.Mismatches)
+			}
+			for i, m := range me.Mismatches {
+				if m.String() != tc.mismatches[i] {
+					t.Errorf("expected mismatch %q, got %q", tc.mismatches[i], m.String())
+				}
+			}
+		})
+	}
+}
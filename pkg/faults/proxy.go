@@ -0,0 +1,395 @@
+package faults
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/cert"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const (
+	// address the proxy listens on, if none is specified
+	DefaultAddress string = "127.0.0.1:0"
+
+	// seconds clients are asked to wait before retrying throttled requests
+	retryAfterSeconds int = 1
+)
+
+// Proxy is an HTTPS proxy in front of an API server injecting faults into the requests matching its rules.
+// Clients authenticate with a bearer token generated for the proxy, as configured by RESTConfig and Kubeconfig.
+// Requests are then forwarded with the credentials of the upstream rest.Config.
+type Proxy struct {
+	upstream *rest.Config
+	token    string
+	// certificates of the proxy's self-signed serving certificate chain
+	caData []byte
+	// host clients reach the proxy at, if not the listening IP
+	host string
+
+	listener net.Listener
+	server   *http.Server
+
+	mu    sync.Mutex
+	rules []*activeRule
+}
+
+type activeRule struct {
+	Rule
+
+	injected int
+}
+
+// ProxyOptions configures the address a proxy listens on and the one clients reach it at
+type ProxyOptions struct {
+	// Address the proxy listens on, DefaultAddress if empty
+	Address string
+	// Host, i.e. name or IP, clients reach the proxy at, e.g. a host reachable from a cluster's pods.
+	// The IP the proxy listens on is used if empty.
+	AdvertisedHost string
+	// Additional names and IPs the proxy's serving certificate is valid for
+	SANs []string
+}
+
+// Start starts a proxy for the API server of cfg listening on DefaultAddress.
+// The proxy is closed when ctx is done.
+func Start(ctx context.Context, cfg *rest.Config) (*Proxy, error) {
+	return StartWithOptions(ctx, cfg, ProxyOptions{})
+}
+
+// StartOnAddress starts a proxy for the API server of cfg listening on address.
+// The proxy is closed when ctx is done.
+func StartOnAddress(ctx context.Context, cfg *rest.Config, address string) (*Proxy, error) {
+	return StartWithOptions(ctx, cfg, ProxyOptions{Address: address})
+}
+
+// StartWithOptions starts a proxy for the API server of cfg configured by opts.
+// The proxy is closed when ctx is done.
+func StartWithOptions(ctx context.Context, cfg *rest.Config, opts ProxyOptions) (*Proxy, error) {
+	u, err := upstreamURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Address == "" {
+		opts.Address = DefaultAddress
+	}
+	l, err := net.Listen("tcp", opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	crt, key, err := generateCertificate(l.Addr().(*net.TCPAddr).IP, opts)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	kp, err := tls.X509KeyPair(crt, key)
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	p := &Proxy{upstream: rest.CopyConfig(cfg), token: t, caData: crt, host: opts.AdvertisedHost, listener: l}
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(u)
+			// clients authenticate with the proxy, not with the API server
+			r.Out.Header.Del("Authorization")
+		},
+		Transport:      rt,
+		FlushInterval:  -1,
+		ModifyResponse: p.modifyResponse,
+	}
+	p.server = &http.Server{Handler: p.handler(rp), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		tl := tls.NewListener(l, &tls.Config{Certificates: []tls.Certificate{kp}, MinVersion: tls.VersionTLS12})
+		if err := p.server.Serve(tl); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("error serving fault injection proxy: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		p.Close()
+	}()
+	return p, nil
+}
+
+// Close stops the proxy
+func (p *Proxy) Close() error {
+	return p.server.Close()
+}
+
+// URL returns the URL clients reach the proxy at
+func (p *Proxy) URL() string {
+	a := p.listener.Addr().(*net.TCPAddr)
+	if p.host == "" {
+		return "https://" + a.String()
+	}
+	return "https://" + net.JoinHostPort(p.host, strconv.Itoa(a.Port))
+}
+
+// RESTConfig returns a rest.Config for the proxy, authenticating with the proxy's token
+func (p *Proxy) RESTConfig() *rest.Config {
+	return &rest.Config{
+		Host:            p.URL(),
+		BearerToken:     p.token,
+		TLSClientConfig: rest.TLSClientConfig{CAData: p.caData},
+		APIPath:         p.upstream.APIPath,
+		UserAgent:       p.upstream.UserAgent,
+		QPS:             p.upstream.QPS,
+		Burst:           p.upstream.Burst,
+		Timeout:         p.upstream.Timeout,
+	}
+}
+
+// Kubeconfig returns a kubeconfig for the proxy authenticating with the proxy's token, e.g. for an operator under test
+func (p *Proxy) Kubeconfig(namespace *string) ([]byte, error) {
+	ct := &clientcmdapi.Context{Cluster: "proxy", AuthInfo: "proxy", Namespace: "default"}
+	if namespace != nil {
+		ct.Namespace = *namespace
+	}
+
+	return clientcmd.Write(clientcmdapi.Config{
+		Kind:           "Config",
+		APIVersion:     "v1",
+		Clusters:       map[string]*clientcmdapi.Cluster{"proxy": {Server: p.URL(), CertificateAuthorityData: p.caData}},
+		AuthInfos:      map[string]*clientcmdapi.AuthInfo{"proxy": {Token: p.token}},
+		Contexts:       map[string]*clientcmdapi.Context{"proxy": ct},
+		CurrentContext: "proxy",
+	})
+}
+
+// AddRule adds a rule to the proxy. Rules are evaluated in the order they were added.
+func (p *Proxy) AddRule(r Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rules = append(p.rules, &activeRule{Rule: r})
+}
+
+// Reset removes all the rules
+func (p *Proxy) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rules = nil
+}
+
+// match returns the first rule matching the request and accounts for its injection
+func (p *Proxy) match(i kube.RequestInfo) *Rule {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, r := range p.rules {
+		if (r.Times == 0 || r.injected < r.Times) && r.matches(i) {
+			r.injected++
+			lr := r.Rule
+			return &lr
+		}
+	}
+	return nil
+}
+
+type ruleKey struct{}
+
+func (p *Proxy) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !p.authenticated(req) {
+			writeError(w, kerrors.NewUnauthorized("invalid fault injection proxy token"))
+			return
+		}
+
+		i := kube.ParseRequestInfo(req)
+		r := p.match(i)
+		if r == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if r.Latency > 0 {
+			select {
+			case <-time.After(r.Latency):
+			case <-req.Context().Done():
+				return
+			}
+		}
+
+		if r.StatusCode != 0 {
+			log.Printf("fault injection proxy: responding with %d to %s %s", r.StatusCode, i.Verb, req.URL.Path)
+			writeStatus(w, r.StatusCode, i)
+			return
+		}
+
+		if r.DropWatchAfter != nil && i.Verb == "watch" {
+			// events are counted on JSON streams
+			req.Header.Set("Accept", "application/json")
+			req = req.WithContext(context.WithValue(req.Context(), ruleKey{}, r))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// authenticated returns whether the request carries the proxy's token
+func (p *Proxy) authenticated(req *http.Request) bool {
+	t, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(t), []byte(p.token)) == 1
+}
+
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	r, ok := resp.Request.Context().Value(ruleKey{}).(*Rule)
+	if !ok || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	log.Printf("fault injection proxy: dropping watch %s after %d events", resp.Request.URL.Path, *r.DropWatchAfter)
+	resp.Body = &droppingBody{
+		ReadCloser: resp.Body,
+		reader:     bufio.NewReader(resp.Body),
+		left:       *r.DropWatchAfter,
+		truncate:   r.TruncateWatch,
+	}
+	return nil
+}
+
+// droppingBody ends a JSON watch stream after a number of events,
+// optionally sending half of the following one
+type droppingBody struct {
+	io.ReadCloser
+
+	reader   *bufio.Reader
+	left     int
+	truncate bool
+	pending  []byte
+}
+
+func (b *droppingBody) Read(p []byte) (int, error) {
+	if len(b.pending) == 0 {
+		// the stream is dropped without waiting for an event, unless it has to be truncated
+		if b.left < 0 || (b.left == 0 && !b.truncate) {
+			return 0, io.EOF
+		}
+
+		e, err := b.reader.ReadBytes('\n')
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case b.left > 0:
+			b.pending = e
+		case b.truncate:
+			b.pending = e[:len(e)/2]
+		}
+		b.left--
+
+		if len(b.pending) == 0 {
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// writeStatus writes a Status error with the given code, like the API server would
+func writeStatus(w http.ResponseWriter, code int, i kube.RequestInfo) {
+	gr := schema.GroupResource{Group: i.Group, Resource: i.Resource}
+	var err *kerrors.StatusError
+	switch code {
+	case http.StatusConflict:
+		err = kerrors.NewConflict(gr, i.Name, fmt.Errorf("injected conflict"))
+	case http.StatusTooManyRequests:
+		err = kerrors.NewTooManyRequests("injected too many requests", retryAfterSeconds)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	case http.StatusServiceUnavailable:
+		err = kerrors.NewServiceUnavailable("injected service unavailable")
+	default:
+		err = kerrors.NewGenericServerResponse(code, i.Verb, gr, i.Name, "injected error", 0, false)
+	}
+
+	writeError(w, err)
+}
+
+// writeError writes the Status of err
+func writeError(w http.ResponseWriter, err *kerrors.StatusError) {
+	s := err.Status()
+	s.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(s.Code))
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+// generateCertificate returns a self-signed serving certificate chain and its key,
+// valid for localhost, the listening IP, the advertised host and the additional SANs
+func generateCertificate(ip net.IP, opts ProxyOptions) ([]byte, []byte, error) {
+	ii, nn := []net.IP{ip, net.IPv4(127, 0, 0, 1)}, []string{}
+	for _, h := range append([]string{opts.AdvertisedHost}, opts.SANs...) {
+		switch hip := net.ParseIP(h); {
+		case h == "":
+		case hip != nil:
+			ii = append(ii, hip)
+		default:
+			nn = append(nn, h)
+		}
+	}
+	return cert.GenerateSelfSignedCertKey("localhost", ii, nn)
+}
+
+// newToken returns a random token for clients to authenticate with the proxy
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func upstreamURL(cfg *rest.Config) (*url.URL, error) {
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		// host:port, as accepted by rest.Config
+		u, err = url.Parse("https://" + cfg.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,276 @@
+package faults_test
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/filariow/mctest/pkg/faults"
+)
+
+// fakeAPIServer serves ConfigMaps and streams three events on watches, keeping them open
+// until the client stops them, recording the Authorization header of the requests
+type fakeAPIServer struct {
+	mu    sync.Mutex
+	auths []string
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.auths = append(s.auths, r.Header.Get("Authorization"))
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	cm := corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"},
+	}
+	if r.URL.Query().Get("watch") != "true" {
+		_ = e.Encode(cm)
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		o := runtime.RawExtension{}
+		o.Raw, _ = json.Marshal(cm)
+		_ = e.Encode(metav1.WatchEvent{Type: string(watch.Modified), Object: o})
+		w.(http.Flusher).Flush()
+	}
+	<-r.Context().Done()
+}
+
+func startProxy(t *testing.T, rr ...faults.Rule) (*fakeAPIServer, *faults.Proxy, kubernetes.Interface) {
+	t.Helper()
+
+	return startProxyWithOptions(t, faults.ProxyOptions{}, rr...)
+}
+
+func startProxyWithOptions(t *testing.T, opts faults.ProxyOptions, rr ...faults.Rule) (*fakeAPIServer, *faults.Proxy, kubernetes.Interface) {
+	t.Helper()
+
+	s := &fakeAPIServer{}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	p, err := faults.StartWithOptions(ctx, &rest.Config{Host: srv.URL, BearerToken: "admin"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rr {
+		p.AddRule(r)
+	}
+
+	cli, err := kubernetes.NewForConfig(p.RESTConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, p, cli
+}
+
+// countEvents counts the events of a watch on ConfigMaps until it is closed or a second elapsed,
+// returning whether it was closed
+func countEvents(t *testing.T, cli kubernetes.Interface) (int, bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	w, err := cli.CoreV1().ConfigMaps("test").Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	n := 0
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return n, ctx.Err() == nil
+			}
+			if e.Type == watch.Modified {
+				n++
+			}
+		case <-ctx.Done():
+			return n, false
+		}
+	}
+}
+
+func Test_Proxy(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("requests are forwarded with upstream credentials", func(t *testing.T) {
+		s, _, cli := startProxy(t)
+
+		if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if n, closed := countEvents(t, cli); n != 3 || closed {
+			t.Errorf("expected 3 events on an open watch, got %d (closed: %t)", n, closed)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, a := range s.auths {
+			if a != "Bearer admin" {
+				t.Errorf("expected requests to be authenticated as admin, got %q", a)
+			}
+		}
+	})
+
+	t.Run("requests without the proxy token are rejected", func(t *testing.T) {
+		s, p, _ := startProxy(t)
+
+		for _, tk := range []string{"", "admin"} {
+			cfg := p.RESTConfig()
+			cfg.BearerToken = tk
+			cli, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); !kerrors.IsUnauthorized(err) {
+				t.Errorf("expected Unauthorized with token %q, got %v", tk, err)
+			}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(s.auths) != 0 {
+			t.Errorf("expected no request to be forwarded, got %d", len(s.auths))
+		}
+	})
+
+	t.Run("kubeconfig authenticates with the proxy token", func(t *testing.T) {
+		_, p, _ := startProxy(t)
+
+		kc, err := p.Kubeconfig(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := clientcmd.RESTConfigFromKubeConfig(kc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cli, err := kubernetes.NewForConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("errors are injected the given number of times", func(t *testing.T) {
+		r := faults.RespondWith(http.StatusServiceUnavailable, "get", "configmaps")
+		r.Times = 1
+		_, _, cli := startProxy(t, r, faults.RespondWith(http.StatusConflict, "update", "configmaps"))
+
+		if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); !kerrors.IsServiceUnavailable(err) {
+			t.Errorf("expected ServiceUnavailable, got %v", err)
+		}
+		if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); err != nil {
+			t.Errorf("expected fault to be injected once, got %v", err)
+		}
+
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}}
+		if _, err := cli.CoreV1().ConfigMaps("test").Update(ctx, cm, metav1.UpdateOptions{}); !kerrors.IsConflict(err) {
+			t.Errorf("expected Conflict, got %v", err)
+		}
+	})
+
+	t.Run("latency is injected", func(t *testing.T) {
+		_, _, cli := startProxy(t, faults.Delay(200*time.Millisecond, "get", "configmaps"))
+
+		st := time.Now()
+		if _, err := cli.CoreV1().ConfigMaps("test").Get(ctx, "cm", metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(st); d < 200*time.Millisecond {
+			t.Errorf("expected request to be delayed by 200ms, took %s", d)
+		}
+	})
+
+	t.Run("watches are dropped", func(t *testing.T) {
+		for _, n := range []int{0, 1, 3} {
+			// the stream is quiet after the third event
+			_, _, cli := startProxy(t, faults.DropWatchAfter(n, "configmaps"))
+			if c, closed := countEvents(t, cli); c != n || !closed {
+				t.Errorf("expected watch to be dropped after %d events, got %d (closed: %t)", n, c, closed)
+			}
+		}
+	})
+
+	t.Run("watches are truncated", func(t *testing.T) {
+		_, _, cli := startProxy(t, faults.TruncateWatchAfter(2, "configmaps"))
+		if n, closed := countEvents(t, cli); n != 2 || !closed {
+			t.Errorf("expected watch to be truncated after 2 events, got %d (closed: %t)", n, closed)
+		}
+	})
+}
+
+func Test_Proxy_AdvertisedHost(t *testing.T) {
+	_, p, _ := startProxyWithOptions(t, faults.ProxyOptions{
+		Address:        "0.0.0.0:0",
+		AdvertisedHost: "proxy.mctest.invalid",
+		SANs:           []string{"10.1.2.3"},
+	})
+
+	u, err := url.Parse(p.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Hostname() != "proxy.mctest.invalid" {
+		t.Errorf("expected proxy to be reached at proxy.mctest.invalid, got %s", p.URL())
+	}
+
+	b, _ := pem.Decode(p.RESTConfig().CAData)
+	c, err := x509.ParseCertificate(b.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.VerifyHostname("10.1.2.3"); err != nil {
+		t.Error(err)
+	}
+
+	// the kubeconfig reaches the advertised host, resolved to the local one
+	kc, err := p.Kubeconfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(kc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Dial = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, net.JoinHostPort("127.0.0.1", u.Port()))
+	}
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.CoreV1().ConfigMaps("test").Get(context.Background(), "cm", metav1.GetOptions{}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,74 @@
+package faults
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// Rule injects a fault into the requests it matches.
+// Empty matching fields match any value.
+type Rule struct {
+	// Kubernetes verbs to match, e.g. get, list, watch, create, update, patch, delete
+	Verbs []string
+	// Resources to match, in the resource or resource.group form, e.g. configmaps or deployments.apps
+	Resources []string
+	// Namespaces to match
+	Namespaces []string
+	// Names of the resources to match
+	Names []string
+
+	// Number of requests to inject the fault into, unlimited if zero
+	Times int
+
+	// Latency added before the request is forwarded
+	Latency time.Duration
+	// Status code returned instead of forwarding the request, e.g. 409, 429, 500 or 503
+	StatusCode int
+	// Number of events after which watch streams are dropped
+	DropWatchAfter *int
+	// If set, watch streams are dropped in the middle of the event following DropWatchAfter ones
+	TruncateWatch bool
+}
+
+// DropWatchAfter returns a rule dropping the watches on resources after n events
+func DropWatchAfter(n int, resources ...string) Rule {
+	return Rule{Verbs: []string{"watch"}, Resources: resources, DropWatchAfter: &n}
+}
+
+// TruncateWatchAfter returns a rule truncating the watches on resources in the middle of the event following the first n ones
+func TruncateWatchAfter(n int, resources ...string) Rule {
+	return Rule{Verbs: []string{"watch"}, Resources: resources, DropWatchAfter: &n, TruncateWatch: true}
+}
+
+// RespondWith returns a rule failing the requests with the given verb on resources with status code
+func RespondWith(statusCode int, verb string, resources ...string) Rule {
+	return Rule{Verbs: []string{verb}, Resources: resources, StatusCode: statusCode}
+}
+
+// Delay returns a rule delaying the requests with the given verb on resources
+func Delay(latency time.Duration, verb string, resources ...string) Rule {
+	return Rule{Verbs: []string{verb}, Resources: resources, Latency: latency}
+}
+
+func (r Rule) matches(i kube.RequestInfo) bool {
+	if !i.IsResourceRequest() {
+		return false
+	}
+
+	return matchAny(r.Verbs, i.Verb) &&
+		(len(r.Resources) == 0 || slices.ContainsFunc(r.Resources, func(rs string) bool { return matchResource(rs, i) })) &&
+		matchAny(r.Namespaces, i.Namespace) &&
+		matchAny(r.Names, i.Name)
+}
+
+func matchAny(vv []string, v string) bool {
+	return len(vv) == 0 || slices.Contains(vv, v)
+}
+
+func matchResource(r string, i kube.RequestInfo) bool {
+	n, g, ok := strings.Cut(r, ".")
+	return n == i.Resource && (!ok || g == i.Group)
+}
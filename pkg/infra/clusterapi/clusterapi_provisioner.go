@@ -9,9 +9,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -26,9 +24,13 @@ const clusterKind string = "Cluster"
 
 var ErrClusterNotFound error = fmt.Errorf("error cluster not found")
 
+var _ infra.ClusterNamesProvider = &ClusterAPIProvisioner{}
+
 type ClusterAPIProvisioner struct {
 	Kubernetes kube.Client
 	Manifests  []unstructured.Unstructured
+	// Checks to be passed by clusters to be considered ready by WaitForProvisionedClusters
+	Readiness ReadinessPolicy
 
 	suffix     string
 	clusters   []unstructured.Unstructured
@@ -45,6 +47,7 @@ func NewClusterAPIProvisioner(
 	return &ClusterAPIProvisioner{
 		Kubernetes: kubernetes,
 		Manifests:  manifests,
+		Readiness:  DefaultReadinessPolicy(),
 
 		clusters:   cc,
 		clusterDef: mm,
@@ -60,7 +63,12 @@ func (p *ClusterAPIProvisioner) WaitForProvisionedClusters(ctx context.Context)
 				return err
 			}
 
-			for _, cfg := range cfgs {
+			for _, u := range p.clusters {
+				cfg, ok := cfgs[u.GetName()]
+				if !ok {
+					return fmt.Errorf("%w: kubeconfig for cluster '%s/%s' not found", ErrClusterNotFound, u.GetNamespace(), u.GetName())
+				}
+
 				k, err := kube.New(&cfg, client.Options{Scheme: scheme.Scheme})
 				if err != nil {
 					return err
@@ -77,6 +85,10 @@ func (p *ClusterAPIProvisioner) WaitForProvisionedClusters(ctx context.Context)
 					return err
 				}
 				log.Printf("healthz called successfully, response is: %v", string(hc))
+
+				if err := p.Readiness.Check(ctx, p.Kubernetes, u, k); err != nil {
+					return err
+				}
 			}
 
 			return nil
@@ -93,20 +105,7 @@ func (p *ClusterAPIProvisioner) GetAllAdminKubeconfigs(ctx context.Context) (map
 	// fetch clusters rest.config
 	cfgs := map[string]rest.Config{}
 	for _, u := range p.clusters {
-		sn := fmt.Sprintf("%s-kubeconfig", u.GetName())
-		lctx, lcancel := context.WithTimeout(ctx, 1*time.Minute)
-		defer lcancel()
-
-		cfg, err := poll.DoR(lctx, 10*time.Second, func(ctx context.Context) (*rest.Config, error) {
-			s := corev1.Secret{}
-			t := types.NamespacedName{Namespace: u.GetNamespace(), Name: sn}
-			if err := p.Kubernetes.Get(ctx, t, &s, &client.GetOptions{}); err != nil {
-				return nil, err
-			}
-
-			// etract kubeconfig from secret and build rest.Config
-			return clientcmd.RESTConfigFromKubeConfig(s.Data["value"])
-		})
+		cfg, err := p.getAdminKubeconfig(ctx, u)
 		if err != nil {
 			return nil, err
 		}
@@ -116,6 +115,24 @@ func (p *ClusterAPIProvisioner) GetAllAdminKubeconfigs(ctx context.Context) (map
 	return cfgs, nil
 }
 
+// returns the kubeconfig for the given cluster
+func (p *ClusterAPIProvisioner) getAdminKubeconfig(ctx context.Context, u unstructured.Unstructured) (*rest.Config, error) {
+	sn := fmt.Sprintf("%s-kubeconfig", u.GetName())
+	lctx, lcancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer lcancel()
+
+	return poll.DoR(lctx, 10*time.Second, func(ctx context.Context) (*rest.Config, error) {
+		s := corev1.Secret{}
+		t := types.NamespacedName{Namespace: u.GetNamespace(), Name: sn}
+		if err := p.Kubernetes.Get(ctx, t, &s, &client.GetOptions{}); err != nil {
+			return nil, err
+		}
+
+		// etract kubeconfig from secret and build rest.Config
+		return clientcmd.RESTConfigFromKubeConfig(s.Data["value"])
+	})
+}
+
 // Provision provisions the cluster api manifests for a new cluster
 // It will create Clusters as lasts.
 func (p *ClusterAPIProvisioner) Provision(ctx context.Context) error {
@@ -135,42 +152,8 @@ func (p *ClusterAPIProvisioner) Provision(ctx context.Context) error {
 
 	// wait for cluster status to be ready
 	for _, u := range p.clusters {
-		we, err := p.Kubernetes.WatchForEventOnResourceUnstructured(ctx, u, func(e watch.Event) (bool, error) {
-			// convert to unstructured
-			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(e.Object)
-			if err != nil {
-				return false, err
-			}
-
-			a, ok := m["status"]
-			if !ok {
-				// resource has not been reconciled
-				return false, nil
-			}
-
-			am, ok := a.(map[string]interface{})
-			if !ok {
-				return false, fmt.Errorf("'status' is not a map[string]interface{}")
-			}
-
-			p, ok := am["phase"]
-			if !ok {
-				// resource has not been reconciled
-				return false, nil
-			}
-
-			ps, ok := p.(string)
-			if !ok {
-				return false, fmt.Errorf("field 'status.phase' is not a string")
-			}
-
-			return ps == "Provisioned", nil
-		})
-		if err != nil {
-			return fmt.Errorf("error watching for cluster '%s/%s' status: %w", u.GetNamespace(), u.GetName(), err)
-		}
-		if err := <-we; err != nil {
-			return fmt.Errorf("error watching for cluster '%s/%s' status: %w", u.GetNamespace(), u.GetName(), err)
+		if _, err := p.Kubernetes.WaitFor(ctx, u, kube.FieldEquals("{.status.phase}", "Provisioned")); err != nil {
+			return fmt.Errorf("error waiting for cluster '%s/%s' to be provisioned: %w", u.GetNamespace(), u.GetName(), err)
 		}
 	}
 	return nil
@@ -182,6 +165,15 @@ func (p *ClusterAPIProvisioner) NumClustersProvisionedInProvisionRound() int {
 	return len(p.clusters)
 }
 
+// Returns the names of the clusters that are created by Provision.
+func (p *ClusterAPIProvisioner) ClusterNames() []string {
+	nn := make([]string, len(p.clusters))
+	for i, c := range p.clusters {
+		nn[i] = c.GetName()
+	}
+	return nn
+}
+
 // Unprovisions the clusters previously provisioned.
 // It will delete Clusters as firsts, the other CRs
 func (p *ClusterAPIProvisioner) Unprovision(ctx context.Context) error {
@@ -195,7 +187,7 @@ func (p *ClusterAPIProvisioner) Unprovision(ctx context.Context) error {
 	log.Println("deleting ClusterAPI CRs")
 	// delete other resources
 	for _, u := range p.clusterDef {
-		if err := p.Kubernetes.Delete(ctx, u.DeepCopy(), &client.DeleteOptions{}); !kerrors.IsNotFound(err) {
+		if err := p.Kubernetes.Delete(ctx, u.DeepCopy(), &client.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
 			return err
 		}
 	}
@@ -204,18 +196,81 @@ func (p *ClusterAPIProvisioner) Unprovision(ctx context.Context) error {
 }
 
 // auxiliaries
+
+// lookupCluster returns the provisioned cluster with the given name.
+// The name can be provided with or without the provisioner's suffix.
+func (p *ClusterAPIProvisioner) lookupCluster(name string) (*unstructured.Unstructured, error) {
+	for _, c := range p.clusters {
+		if c.GetName() == name || c.GetName() == fmt.Sprintf("%s-%s", name, p.suffix) {
+			return c.DeepCopy(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, name)
+}
+
+// splitManifests returns the Clusters and the other resources defined in manifests.
+// If clusterSuffix is not nil, it is appended to the name of every resource and the references
+// among them are updated, so that the resources of different provisioners do not clash.
 func splitManifests(manifests []unstructured.Unstructured, clusterSuffix *string) ([]unstructured.Unstructured, []unstructured.Unstructured) {
-	cc, oo := []unstructured.Unstructured{}, []unstructured.Unstructured{}
-	for _, u := range manifests {
+	ll, renamed := make([]unstructured.Unstructured, len(manifests)), map[string]string{}
+	for i, u := range manifests {
 		l := u.DeepCopy()
-		if u.GetKind() == clusterKind {
-			if clusterSuffix != nil {
-				l.SetName(fmt.Sprintf("%s-%s", l.GetName(), *clusterSuffix))
+		if clusterSuffix != nil {
+			n := fmt.Sprintf("%s-%s", l.GetName(), *clusterSuffix)
+			renamed[referenceKey(l.GetKind(), l.GetName())] = n
+			l.SetName(n)
+		}
+		ll[i] = *l
+	}
+
+	cc, oo := []unstructured.Unstructured{}, []unstructured.Unstructured{}
+	for _, l := range ll {
+		updateReferences(l.Object, renamed)
+		if c, ok, _ := unstructured.NestedString(l.Object, "spec", "topology", "class"); ok {
+			if n, ok := renamed[referenceKey("ClusterClass", c)]; ok {
+				_ = unstructured.SetNestedField(l.Object, n, "spec", "topology", "class")
 			}
-			cc = append(cc, *l)
+		}
+
+		if l.GetKind() == clusterKind {
+			cc = append(cc, l)
 		} else {
-			oo = append(oo, *l)
+			oo = append(oo, l)
 		}
 	}
 	return cc, oo
 }
+
+// updateReferences renames the object references, i.e. maps with kind and name,
+// and the cluster names found in o that refer to renamed resources
+func updateReferences(o map[string]interface{}, renamed map[string]string) {
+	for _, v := range o {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			updateReferences(t, renamed)
+		case []interface{}:
+			for _, i := range t {
+				if m, ok := i.(map[string]interface{}); ok {
+					updateReferences(m, renamed)
+				}
+			}
+		}
+	}
+
+	if k, ok := o["kind"].(string); ok {
+		if n, ok := o["name"].(string); ok {
+			if r, ok := renamed[referenceKey(k, n)]; ok {
+				o["name"] = r
+			}
+		}
+	}
+	if n, ok := o["clusterName"].(string); ok {
+		if r, ok := renamed[referenceKey(clusterKind, n)]; ok {
+			o["clusterName"] = r
+		}
+	}
+}
+
+func referenceKey(kind, name string) string {
+	return kind + "/" + name
+}
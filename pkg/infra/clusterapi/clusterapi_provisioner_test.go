@@ -0,0 +1,128 @@
+package clusterapi_test
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const manifests string = `
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: ClusterClass
+metadata:
+  name: class
+spec:
+  infrastructure:
+    ref:
+      apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+      kind: DockerClusterTemplate
+      name: cluster
+  patches:
+  - definitions:
+    - selector:
+        apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+        kind: DockerClusterTemplate
+  workers:
+    machineDeployments:
+    - class: default-worker
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: DockerClusterTemplate
+metadata:
+  name: cluster
+---
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: MachineDeployment
+metadata:
+  name: md
+spec:
+  clusterName: c
+  template:
+    spec:
+      infrastructureRef:
+        apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+        kind: DockerMachineTemplate
+        name: external
+---
+apiVersion: cluster.x-k8s.io/v1beta1
+kind: Cluster
+metadata:
+  name: c
+spec:
+  topology:
+    class: class
+`
+
+func Test_splitManifests(t *testing.T) {
+	mm, err := kube.ParseManifests(manifests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := func(u unstructured.Unstructured, fields ...string) string {
+		t.Helper()
+
+		v, ok, err := unstructured.NestedFieldNoCopy(u.Object, fields...)
+		if err != nil || !ok {
+			t.Fatalf("field %v not found in %s: %v", fields, u.GetName(), err)
+		}
+		s, _ := v.(string)
+		return s
+	}
+
+	t.Run("resources and references are suffixed", func(t *testing.T) {
+		s := "one"
+		cc, oo := clusterapi.SplitManifests(mm, &s)
+
+		if len(cc) != 1 || cc[0].GetName() != "c-one" {
+			t.Fatalf("expected cluster c-one, got %v", cc)
+		}
+		if c := field(cc[0], "spec", "topology", "class"); c != "class-one" {
+			t.Errorf("expected Cluster to refer to ClusterClass class-one, got %s", c)
+		}
+
+		if len(oo) != 3 {
+			t.Fatalf("expected 3 resources, got %d", len(oo))
+		}
+		for i, n := range []string{"class-one", "cluster-one", "md-one"} {
+			if oo[i].GetName() != n {
+				t.Errorf("expected resource %s, got %s", n, oo[i].GetName())
+			}
+		}
+
+		if n := field(oo[0], "spec", "infrastructure", "ref", "name"); n != "cluster-one" {
+			t.Errorf("expected ClusterClass to refer to template cluster-one, got %s", n)
+		}
+		ww, _, _ := unstructured.NestedSlice(oo[0].Object, "spec", "workers", "machineDeployments")
+		if c := ww[0].(map[string]interface{})["class"]; c != "default-worker" {
+			t.Errorf("expected worker class not to be renamed, got %s", c)
+		}
+		if n := field(oo[2], "spec", "clusterName"); n != "c-one" {
+			t.Errorf("expected MachineDeployment to refer to cluster c-one, got %s", n)
+		}
+		if n := field(oo[2], "spec", "template", "spec", "infrastructureRef", "name"); n != "external" {
+			t.Errorf("expected reference to a resource not in the manifests to be kept, got %s", n)
+		}
+
+		if mm[0].GetName() != "class" {
+			t.Errorf("expected manifests not to be modified, got %s", mm[0].GetName())
+		}
+	})
+
+	t.Run("names are kept without suffix", func(t *testing.T) {
+		cc, oo := clusterapi.SplitManifests(mm, nil)
+
+		if len(cc) != 1 || cc[0].GetName() != "c" {
+			t.Fatalf("expected cluster c, got %v", cc)
+		}
+		if c := field(cc[0], "spec", "topology", "class"); c != "class" {
+			t.Errorf("expected Cluster to refer to ClusterClass class, got %s", c)
+		}
+		if len(oo) != 3 || oo[0].GetName() != "class" {
+			t.Errorf("expected 3 resources starting with class, got %v", oo)
+		}
+	})
+}
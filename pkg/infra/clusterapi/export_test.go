@@ -0,0 +1,8 @@
+package clusterapi
+
+// unexported functions exposed to tests
+var (
+	CheckReplicasUpdated    = checkReplicasUpdated
+	WaitForNodesConvergence = waitForNodesConvergence
+	SplitManifests          = splitManifests
+)
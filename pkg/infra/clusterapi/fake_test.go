@@ -0,0 +1,81 @@
+package clusterapi_test
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const capiAPIVersion string = "cluster.x-k8s.io/v1beta1"
+
+var capiGroupVersion = schema.GroupVersion{Group: "cluster.x-k8s.io", Version: "v1beta1"}
+
+// fakeKube is a kube.Client serving Get and List from a fake client
+type fakeKube struct {
+	kube.Client
+
+	cli client.WithWatch
+}
+
+func newFakeKube(objs ...client.Object) *fakeKube {
+	m := meta.NewDefaultRESTMapper([]schema.GroupVersion{capiGroupVersion, corev1.SchemeGroupVersion})
+	for _, k := range []string{"Cluster", "MachineDeployment", "Machine", "KubeadmControlPlane"} {
+		m.Add(capiGroupVersion.WithKind(k), meta.RESTScopeNamespace)
+	}
+	m.Add(corev1.SchemeGroupVersion.WithKind("Node"), meta.RESTScopeRoot)
+
+	return &fakeKube{
+		cli: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithRESTMapper(m).WithObjects(objs...).Build(),
+	}
+}
+
+func (k *fakeKube) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return k.cli.Get(ctx, key, obj, opts...)
+}
+
+func (k *fakeKube) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return k.cli.List(ctx, list, opts...)
+}
+
+// capiObject returns a ClusterAPI object of the given kind in namespace test
+// belonging to cluster c, with the given fields
+func capiObject(kind, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for k, v := range fields {
+		u.Object[k] = v
+	}
+	u.SetAPIVersion(capiAPIVersion)
+	u.SetKind(kind)
+	u.SetNamespace("test")
+	u.SetName(name)
+	if kind != "Cluster" {
+		u.SetLabels(map[string]string{"cluster.x-k8s.io/cluster-name": "c"})
+	}
+	return u
+}
+
+func conditions(cc map[string]string) []interface{} {
+	ii := []interface{}{}
+	for t, s := range cc {
+		ii = append(ii, map[string]interface{}{"type": t, "status": s})
+	}
+	return ii
+}
+
+func node(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}},
+		},
+	}
+}
@@ -0,0 +1,213 @@
+package clusterapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+var _ infra.MachineManager = &ClusterAPIProvisioner{}
+
+var ErrMachineDeploymentNotFound error = fmt.Errorf("error machine deployment not found in cluster topology")
+var ErrMachineNotFound error = fmt.Errorf("error machine not found")
+
+// ScaleWorkers sets the replicas of the given topology's MachineDeployment
+// and waits for the workload cluster's nodes to converge.
+// If pool is empty and the cluster has just one MachineDeployment, that one is scaled.
+func (p *ClusterAPIProvisioner) ScaleWorkers(ctx context.Context, clusterName, pool string, replicas int) error {
+	c, err := p.lookupCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	t := types.NamespacedName{Namespace: c.GetNamespace(), Name: c.GetName()}
+	if err := p.Kubernetes.Get(ctx, t, c, &client.GetOptions{}); err != nil {
+		return err
+	}
+
+	mm, _, err := unstructured.NestedSlice(c.Object, "spec", "topology", "workers", "machineDeployments")
+	if err != nil {
+		return err
+	}
+
+	i, err := findMachineDeployment(mm, pool)
+	if err != nil {
+		return fmt.Errorf("cluster '%s/%s': %w", c.GetNamespace(), c.GetName(), err)
+	}
+
+	// patch the replicas, checking the pool has not been moved in the meantime
+	pp := fmt.Sprintf("/spec/topology/workers/machineDeployments/%d", i)
+	pd, err := json.Marshal([]map[string]interface{}{
+		{"op": "test", "path": pp + "/name", "value": mm[i].(map[string]interface{})["name"]},
+		{"op": "add", "path": pp + "/replicas", "value": replicas},
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.Kubernetes.Patch(ctx, c, client.RawPatch(types.JSONPatchType, pd), &client.PatchOptions{}); err != nil {
+		return fmt.Errorf("error scaling workers of cluster '%s/%s' to %d: %w", c.GetNamespace(), c.GetName(), replicas, err)
+	}
+
+	return p.waitForNodesConvergence(ctx, *c)
+}
+
+// DeleteMachine deletes one of the cluster's Machines matching the given selector
+// and waits for the workload cluster's nodes to converge.
+func (p *ClusterAPIProvisioner) DeleteMachine(ctx context.Context, clusterName string, selector labels.Selector) error {
+	c, err := p.lookupCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	mm, err := listClusterResources(ctx, p.Kubernetes, *c, "MachineList")
+	if err != nil {
+		return err
+	}
+
+	cm := []unstructured.Unstructured{}
+	for _, m := range mm.Items {
+		if selector.Matches(labels.Set(m.GetLabels())) && m.GetDeletionTimestamp() == nil {
+			cm = append(cm, m)
+		}
+	}
+	if len(cm) == 0 {
+		return fmt.Errorf("%w: cluster '%s/%s', selector '%s'", ErrMachineNotFound, c.GetNamespace(), c.GetName(), selector)
+	}
+	sort.Slice(cm, func(i, j int) bool { return cm[i].GetName() < cm[j].GetName() })
+
+	m := cm[0]
+	log.Printf("deleting machine '%s/%s' of cluster '%s'", m.GetNamespace(), m.GetName(), c.GetName())
+	if err := p.Kubernetes.DeleteAndWait(ctx, m, &client.DeleteOptions{}); err != nil {
+		return fmt.Errorf("error deleting machine '%s/%s': %w", m.GetNamespace(), m.GetName(), err)
+	}
+
+	return p.waitForNodesConvergence(ctx, *c)
+}
+
+// waitForNodesConvergence waits for the workload cluster to have as many nodes
+// as the replicas declared in the cluster's topology and for all the cluster's
+// Machines to be Running.
+func (p *ClusterAPIProvisioner) waitForNodesConvergence(ctx context.Context, cluster unstructured.Unstructured) error {
+	cfg, err := p.getAdminKubeconfig(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	k, err := kube.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		return err
+	}
+
+	return waitForNodesConvergence(ctx, p.Kubernetes, k, cluster)
+}
+
+// waitForNodesConvergence polls the management and the workload cluster until the nodes converge,
+// see ClusterAPIProvisioner.waitForNodesConvergence
+func waitForNodesConvergence(ctx context.Context, management, workload kube.Client, cluster unstructured.Unstructured) error {
+	return poll.Do(ctx, 10*time.Second, func(ctx context.Context) error {
+		if err := func() error {
+			c := cluster.DeepCopy()
+			t := types.NamespacedName{Namespace: cluster.GetNamespace(), Name: cluster.GetName()}
+			if err := management.Get(ctx, t, c, &client.GetOptions{}); err != nil {
+				return err
+			}
+
+			e, err := expectedNodes(*c)
+			if err != nil {
+				return err
+			}
+
+			nn := corev1.NodeList{}
+			if err := workload.List(ctx, &nn); err != nil {
+				return err
+			}
+
+			mm, err := listClusterResources(ctx, management, cluster, "MachineList")
+			if err != nil {
+				return err
+			}
+
+			errs := []error{}
+			if n := int64(len(nn.Items)); n != e {
+				errs = append(errs, fmt.Errorf("workload cluster has %d nodes, %d expected", n, e))
+			}
+			for _, m := range mm.Items {
+				if ph, _, _ := unstructured.NestedString(m.Object, "status", "phase"); ph != "Running" {
+					errs = append(errs, fmt.Errorf("machine %s is in phase %s", m.GetName(), ph))
+				}
+			}
+			return errors.Join(errs...)
+		}(); err != nil {
+			log.Printf("nodes of cluster '%s/%s' not converged yet: %v", cluster.GetNamespace(), cluster.GetName(), err)
+			return err
+		}
+		return nil
+	})
+}
+
+// expectedNodes returns the number of nodes declared in the cluster's topology
+func expectedNodes(cluster unstructured.Unstructured) (int64, error) {
+	cp, ok, err := unstructured.NestedInt64(cluster.Object, "spec", "topology", "controlPlane", "replicas")
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// defaulted by the control plane provider
+		cp = 1
+	}
+
+	e := cp
+	for _, w := range []string{"machineDeployments", "machinePools"} {
+		ww, _, err := unstructured.NestedSlice(cluster.Object, "spec", "topology", "workers", w)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, i := range ww {
+			im, ok := i.(map[string]interface{})
+			if !ok {
+				return 0, fmt.Errorf("unexpected %s entry in cluster '%s/%s' topology", w, cluster.GetNamespace(), cluster.GetName())
+			}
+
+			r, _, err := unstructured.NestedInt64(im, "replicas")
+			if err != nil {
+				return 0, err
+			}
+			e += r
+		}
+	}
+	return e, nil
+}
+
+// findMachineDeployment returns the index of the topology's MachineDeployment with the given name.
+// If name is empty, the index of the only MachineDeployment is returned.
+func findMachineDeployment(machineDeployments []interface{}, name string) (int, error) {
+	if name == "" {
+		if len(machineDeployments) != 1 {
+			return 0, fmt.Errorf("%w: pool name is required when the topology has %d MachineDeployments", ErrMachineDeploymentNotFound, len(machineDeployments))
+		}
+		return 0, nil
+	}
+
+	for i, m := range machineDeployments {
+		if mm, ok := m.(map[string]interface{}); ok && mm["name"] == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrMachineDeploymentNotFound, name)
+}
@@ -0,0 +1,104 @@
+package clusterapi_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+func Test_waitForNodesConvergence(t *testing.T) {
+	machine := func(name, phase string) client.Object {
+		return capiObject("Machine", name, map[string]interface{}{
+			"status": map[string]interface{}{"phase": phase},
+		})
+	}
+	topology := map[string]interface{}{
+		"controlPlane": map[string]interface{}{"replicas": int64(1)},
+		"workers": map[string]interface{}{
+			"machineDeployments": []interface{}{
+				map[string]interface{}{"name": "md-0", "replicas": int64(2)},
+			},
+		},
+	}
+
+	tt := []struct {
+		name     string
+		topology map[string]interface{}
+		machines []client.Object
+		nodes    []client.Object
+		// substrings of the expected error, nil if the nodes are expected to converge
+		expected []string
+	}{
+		{
+			name:     "converged",
+			topology: topology,
+			machines: []client.Object{machine("cp-0", "Running"), machine("md-0", "Running"), machine("md-1", "Running")},
+			nodes:    []client.Object{node("cp-0", corev1.ConditionTrue), node("md-0", corev1.ConditionTrue), node("md-1", corev1.ConditionTrue)},
+		},
+		{
+			name:     "control plane replicas defaulted",
+			topology: map[string]interface{}{},
+			machines: []client.Object{machine("cp-0", "Running")},
+			nodes:    []client.Object{node("cp-0", corev1.ConditionTrue)},
+		},
+		{
+			name:     "node not yet removed",
+			topology: topology,
+			machines: []client.Object{machine("cp-0", "Running"), machine("md-0", "Running"), machine("md-1", "Running")},
+			nodes: []client.Object{
+				node("cp-0", corev1.ConditionTrue), node("md-0", corev1.ConditionTrue),
+				node("md-1", corev1.ConditionTrue), node("md-2", corev1.ConditionFalse),
+			},
+			expected: []string{"workload cluster has 4 nodes, 3 expected"},
+		},
+		{
+			name:     "replacement machine provisioning",
+			topology: topology,
+			machines: []client.Object{machine("cp-0", "Running"), machine("md-0", "Running"), machine("md-2", "Provisioning")},
+			nodes:    []client.Object{node("cp-0", corev1.ConditionTrue), node("md-0", corev1.ConditionTrue)},
+			expected: []string{
+				"workload cluster has 2 nodes, 3 expected",
+				"machine md-2 is in phase Provisioning",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := capiObject("Cluster", "c", map[string]interface{}{
+				"spec": map[string]interface{}{"topology": tc.topology},
+			})
+			m := newFakeKube(append(tc.machines, c)...)
+			w := newFakeKube(tc.nodes...)
+
+			// the first poll is immediate, no need to wait for the retry interval
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err := clusterapi.WaitForNodesConvergence(ctx, m, w, *c)
+			if tc.expected == nil {
+				if err != nil {
+					t.Errorf("expected nodes to converge, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, poll.ErrPollerTimeout) {
+				t.Fatalf("expected ErrPollerTimeout, got %v", err)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("expected error to contain %q, got %v", e, err)
+				}
+			}
+		})
+	}
+}
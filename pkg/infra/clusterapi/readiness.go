@@ -0,0 +1,237 @@
+package clusterapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const (
+	clusterNameLabel string = "cluster.x-k8s.io/cluster-name"
+
+	ConditionControlPlaneInitialized string = "ControlPlaneInitialized"
+	ConditionControlPlaneReady       string = "ControlPlaneReady"
+	ConditionInfrastructureReady     string = "InfrastructureReady"
+)
+
+var ErrClusterNotReady error = fmt.Errorf("error cluster not ready")
+
+var (
+	// CoreDNSPods selects the CoreDNS pods deployed by kubeadm
+	CoreDNSPods = PodsReadiness{Name: "CoreDNS", Namespace: "kube-system", Selector: "k8s-app=kube-dns"}
+	// KindnetPods selects the pods of the kindnet CNI
+	KindnetPods = PodsReadiness{Name: "kindnet", Namespace: "kube-system", Selector: "app=kindnet"}
+	// CalicoPods selects the pods of the Calico CNI
+	CalicoPods = PodsReadiness{Name: "Calico", Namespace: "kube-system", Selector: "k8s-app=calico-node"}
+)
+
+// ReadinessPolicy defines the checks a provisioned cluster has to pass
+// before it is considered ready by WaitForProvisionedClusters.
+type ReadinessPolicy struct {
+	// Cluster's conditions that are required to be True
+	ClusterConditions []string
+	// If true, all the MachineDeployments of the cluster are required to have all their replicas ready
+	MachineDeploymentsReady bool
+	// If true, all the nodes of the workload cluster are required to be Ready
+	NodesReady bool
+	// Pods in the workload cluster that are required to be Ready, e.g. CNI and CoreDNS ones
+	SystemPods []PodsReadiness
+}
+
+// PodsReadiness selects a set of pods in the workload cluster
+// that are required to be Ready.
+type PodsReadiness struct {
+	// Name used in error messages
+	Name      string
+	Namespace string
+	// Label selector in the format accepted by labels.Parse
+	Selector string
+}
+
+// DefaultReadinessPolicy requires the control plane and the infrastructure to be ready
+// and all the MachineDeployments to have their replicas ready.
+//
+// Nodes and system pods readiness are not required as they depend on a CNI
+// being installed in the workload cluster.
+func DefaultReadinessPolicy() ReadinessPolicy {
+	return ReadinessPolicy{
+		ClusterConditions: []string{
+			ConditionControlPlaneInitialized,
+			ConditionControlPlaneReady,
+			ConditionInfrastructureReady,
+		},
+		MachineDeploymentsReady: true,
+	}
+}
+
+// Check evaluates the policy against the given cluster.
+// management is the client for the ClusterAPI management cluster,
+// workload the one for the provisioned cluster.
+// The returned error lists all the checks that blocked readiness.
+func (r ReadinessPolicy) Check(ctx context.Context, management kube.Client, cluster unstructured.Unstructured, workload kube.Client) error {
+	errs := []error{}
+
+	if len(r.ClusterConditions) > 0 {
+		c := cluster.DeepCopy()
+		t := types.NamespacedName{Namespace: cluster.GetNamespace(), Name: cluster.GetName()}
+		if err := management.Get(ctx, t, c, &client.GetOptions{}); err != nil {
+			return err
+		}
+
+		for _, ct := range r.ClusterConditions {
+			if err := checkCondition(*c, ct); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if r.MachineDeploymentsReady {
+		if err := checkMachineDeployments(ctx, management, cluster); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.NodesReady {
+		if err := checkNodes(ctx, workload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, sp := range r.SystemPods {
+		if err := checkPods(ctx, workload, sp); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: cluster '%s/%s': %w", ErrClusterNotReady, cluster.GetNamespace(), cluster.GetName(), err)
+	}
+	return nil
+}
+
+func checkCondition(u unstructured.Unstructured, conditionType string) error {
+	cc, _, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cc {
+		cm, ok := c.(map[string]interface{})
+		if !ok || cm["type"] != conditionType {
+			continue
+		}
+
+		if cm["status"] != string(corev1.ConditionTrue) {
+			return fmt.Errorf("condition %s is %v (reason: %v): %v", conditionType, cm["status"], cm["reason"], cm["message"])
+		}
+		return nil
+	}
+
+	return fmt.Errorf("condition %s not found", conditionType)
+}
+
+func checkMachineDeployments(ctx context.Context, management kube.Client, cluster unstructured.Unstructured) error {
+	mm, err := listClusterResources(ctx, management, cluster, "MachineDeploymentList")
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, m := range mm.Items {
+		d, _, err := unstructured.NestedInt64(m.Object, "spec", "replicas")
+		if err != nil {
+			return err
+		}
+		r, _, err := unstructured.NestedInt64(m.Object, "status", "readyReplicas")
+		if err != nil {
+			return err
+		}
+
+		if r != d {
+			errs = append(errs, fmt.Errorf("MachineDeployment %s has %d/%d ready replicas", m.GetName(), r, d))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listClusterResources lists the ClusterAPI resources of the given list kind
+// that belong to the given cluster.
+func listClusterResources(ctx context.Context, management kube.Client, cluster unstructured.Unstructured, listKind string) (*unstructured.UnstructuredList, error) {
+	ll := unstructured.UnstructuredList{}
+	ll.SetAPIVersion(cluster.GetAPIVersion())
+	ll.SetKind(listKind)
+	s := labels.SelectorFromSet(labels.Set{clusterNameLabel: cluster.GetName()})
+	if err := management.List(ctx, &ll, client.InNamespace(cluster.GetNamespace()), client.MatchingLabelsSelector{Selector: s}); err != nil {
+		return nil, err
+	}
+	return &ll, nil
+}
+
+func checkNodes(ctx context.Context, workload kube.Client) error {
+	nn := corev1.NodeList{}
+	if err := workload.List(ctx, &nn); err != nil {
+		return err
+	}
+
+	if len(nn.Items) == 0 {
+		return fmt.Errorf("no nodes found")
+	}
+
+	errs := []error{}
+	for _, n := range nn.Items {
+		if !isNodeReady(n) {
+			errs = append(errs, fmt.Errorf("node %s is not Ready", n.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isNodeReady(n corev1.Node) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func checkPods(ctx context.Context, workload kube.Client, r PodsReadiness) error {
+	s, err := labels.Parse(r.Selector)
+	if err != nil {
+		return err
+	}
+
+	pp := corev1.PodList{}
+	if err := workload.List(ctx, &pp, client.InNamespace(r.Namespace), client.MatchingLabelsSelector{Selector: s}); err != nil {
+		return err
+	}
+
+	if len(pp.Items) == 0 {
+		return fmt.Errorf("no %s pods found in namespace %s with selector %s", r.Name, r.Namespace, r.Selector)
+	}
+
+	errs := []error{}
+	for _, p := range pp.Items {
+		if !isPodReady(p) {
+			errs = append(errs, fmt.Errorf("%s pod %s/%s is not Ready (phase: %s)", r.Name, p.Namespace, p.Name, p.Status.Phase))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isPodReady(p corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
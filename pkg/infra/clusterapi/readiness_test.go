@@ -0,0 +1,117 @@
+package clusterapi_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+)
+
+func Test_ReadinessPolicy_Check(t *testing.T) {
+	readyConditions := map[string]string{
+		clusterapi.ConditionControlPlaneInitialized: "True",
+		clusterapi.ConditionControlPlaneReady:       "True",
+		clusterapi.ConditionInfrastructureReady:     "True",
+	}
+	machineDeployment := func(replicas, ready int64) client.Object {
+		return capiObject("MachineDeployment", "md-0", map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": replicas},
+			"status": map[string]interface{}{"readyReplicas": ready},
+		})
+	}
+
+	tt := []struct {
+		name       string
+		policy     clusterapi.ReadinessPolicy
+		conditions map[string]string
+		management []client.Object
+		workload   []client.Object
+		// substrings of the expected error, nil if the cluster is expected to be ready
+		expected []string
+	}{
+		{
+			name:       "ready cluster",
+			policy:     clusterapi.DefaultReadinessPolicy(),
+			conditions: readyConditions,
+			management: []client.Object{machineDeployment(2, 2)},
+		},
+		{
+			name:   "control plane not ready",
+			policy: clusterapi.DefaultReadinessPolicy(),
+			conditions: map[string]string{
+				clusterapi.ConditionControlPlaneInitialized: "True",
+				clusterapi.ConditionControlPlaneReady:       "False",
+			},
+			management: []client.Object{machineDeployment(2, 2)},
+			expected: []string{
+				"condition ControlPlaneReady is False",
+				"condition InfrastructureReady not found",
+			},
+		},
+		{
+			name:       "machine deployment not ready",
+			policy:     clusterapi.DefaultReadinessPolicy(),
+			conditions: readyConditions,
+			management: []client.Object{machineDeployment(3, 1)},
+			expected:   []string{"MachineDeployment md-0 has 1/3 ready replicas"},
+		},
+		{
+			name:       "node not ready",
+			policy:     clusterapi.ReadinessPolicy{NodesReady: true},
+			workload:   []client.Object{node("n-0", corev1.ConditionTrue), node("n-1", corev1.ConditionFalse)},
+			expected:   []string{"node n-1 is not Ready"},
+			conditions: map[string]string{},
+		},
+		{
+			name:   "system pods not found",
+			policy: clusterapi.ReadinessPolicy{SystemPods: []clusterapi.PodsReadiness{clusterapi.CoreDNSPods}},
+			expected: []string{
+				"no CoreDNS pods found in namespace kube-system",
+			},
+			conditions: map[string]string{},
+		},
+		{
+			name:   "system pods not ready",
+			policy: clusterapi.ReadinessPolicy{SystemPods: []clusterapi.PodsReadiness{clusterapi.CoreDNSPods}},
+			workload: []client.Object{&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns", Labels: map[string]string{"k8s-app": "kube-dns"}},
+				Status:     corev1.PodStatus{Phase: corev1.PodPending},
+			}},
+			expected:   []string{"CoreDNS pod kube-system/coredns is not Ready (phase: Pending)"},
+			conditions: map[string]string{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := capiObject("Cluster", "c", map[string]interface{}{
+				"status": map[string]interface{}{"conditions": conditions(tc.conditions)},
+			})
+			m := newFakeKube(append(tc.management, c)...)
+			w := newFakeKube(tc.workload...)
+
+			err := tc.policy.Check(context.Background(), m, *c, w)
+			if tc.expected == nil {
+				if err != nil {
+					t.Errorf("expected cluster to be ready, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, clusterapi.ErrClusterNotReady) {
+				t.Fatalf("expected ErrClusterNotReady, got %v", err)
+			}
+			for _, e := range tc.expected {
+				if !strings.Contains(err.Error(), e) {
+					t.Errorf("expected error to contain %q, got %v", e, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package clusterapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+var _ infra.ClusterUpgrader = &ClusterAPIProvisioner{}
+
+// Upgrade sets the cluster's topology version to the given one and waits
+// for the control plane and the MachineDeployments to report it.
+func (p *ClusterAPIProvisioner) Upgrade(ctx context.Context, clusterName, version string) error {
+	c, err := p.lookupCluster(clusterName)
+	if err != nil {
+		return err
+	}
+
+	// patch topology version
+	pd, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"topology": map[string]interface{}{
+				"version": version,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.Kubernetes.Patch(ctx, c, client.RawPatch(types.MergePatchType, pd), &client.PatchOptions{}); err != nil {
+		return fmt.Errorf("error upgrading cluster '%s/%s' to version %s: %w", c.GetNamespace(), c.GetName(), version, err)
+	}
+
+	// wait for control plane and machine deployments to be upgraded
+	return poll.Do(ctx, 10*time.Second, func(ctx context.Context) error {
+		if err := errors.Join(
+			p.checkControlPlaneVersion(ctx, *c, version),
+			p.checkMachineDeploymentsVersion(ctx, *c, version),
+		); err != nil {
+			log.Printf("cluster '%s/%s' not upgraded yet: %v", c.GetNamespace(), c.GetName(), err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (p *ClusterAPIProvisioner) checkControlPlaneVersion(ctx context.Context, cluster unstructured.Unstructured, version string) error {
+	c := cluster.DeepCopy()
+	t := types.NamespacedName{Namespace: cluster.GetNamespace(), Name: cluster.GetName()}
+	if err := p.Kubernetes.Get(ctx, t, c, &client.GetOptions{}); err != nil {
+		return err
+	}
+
+	ref, ok, err := unstructured.NestedStringMap(c.Object, "spec", "controlPlaneRef")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("control plane reference not set yet")
+	}
+
+	cp := unstructured.Unstructured{}
+	cp.SetAPIVersion(ref["apiVersion"])
+	cp.SetKind(ref["kind"])
+	ns := ref["namespace"]
+	if ns == "" {
+		ns = cluster.GetNamespace()
+	}
+	if err := p.Kubernetes.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref["name"]}, &cp, &client.GetOptions{}); err != nil {
+		return err
+	}
+
+	v, _, err := unstructured.NestedString(cp.Object, "status", "version")
+	if err != nil {
+		return err
+	}
+	if v != version {
+		return fmt.Errorf("control plane %s reports version %s", cp.GetName(), v)
+	}
+
+	return checkReplicasUpdated(cp)
+}
+
+func (p *ClusterAPIProvisioner) checkMachineDeploymentsVersion(ctx context.Context, cluster unstructured.Unstructured, version string) error {
+	mm, err := listClusterResources(ctx, p.Kubernetes, cluster, "MachineDeploymentList")
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, m := range mm.Items {
+		v, _, err := unstructured.NestedString(m.Object, "spec", "template", "spec", "version")
+		if err != nil {
+			return err
+		}
+		if v != version {
+			errs = append(errs, fmt.Errorf("MachineDeployment %s has version %s", m.GetName(), v))
+			continue
+		}
+
+		if err := checkReplicasUpdated(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkReplicasUpdated checks that all the replicas of a control plane
+// or a MachineDeployment are updated and ready, and that no old replica is left.
+func checkReplicasUpdated(u unstructured.Unstructured) error {
+	d, _, err := unstructured.NestedInt64(u.Object, "spec", "replicas")
+	if err != nil {
+		return err
+	}
+
+	rr := map[string]int64{}
+	for _, f := range []string{"replicas", "updatedReplicas", "readyReplicas"} {
+		v, _, err := unstructured.NestedInt64(u.Object, "status", f)
+		if err != nil {
+			return err
+		}
+		rr[f] = v
+	}
+
+	if rr["replicas"] != d || rr["updatedReplicas"] != d || rr["readyReplicas"] != d {
+		return fmt.Errorf("%s %s has %d replicas, %d updated and %d ready, %d desired",
+			u.GetKind(), u.GetName(), rr["replicas"], rr["updatedReplicas"], rr["readyReplicas"], d)
+	}
+	return nil
+}
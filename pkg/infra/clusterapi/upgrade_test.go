@@ -0,0 +1,76 @@
+package clusterapi_test
+
+import (
+	"testing"
+
+	"github.com/filariow/mctest/pkg/infra/clusterapi"
+)
+
+func Test_checkReplicasUpdated(t *testing.T) {
+	tt := []struct {
+		name    string
+		kind    string
+		desired int64
+		status  map[string]interface{}
+		// expected error, empty if the replicas are expected to be updated
+		expected string
+	}{
+		{
+			name:    "machine deployment updated",
+			kind:    "MachineDeployment",
+			desired: 3,
+			status:  map[string]interface{}{"replicas": int64(3), "updatedReplicas": int64(3), "readyReplicas": int64(3)},
+		},
+		{
+			name:    "control plane updated",
+			kind:    "KubeadmControlPlane",
+			desired: 1,
+			status:  map[string]interface{}{"replicas": int64(1), "updatedReplicas": int64(1), "readyReplicas": int64(1)},
+		},
+		{
+			name:     "rollout in progress",
+			kind:     "MachineDeployment",
+			desired:  3,
+			status:   map[string]interface{}{"replicas": int64(4), "updatedReplicas": int64(1), "readyReplicas": int64(3)},
+			expected: "MachineDeployment md-0 has 4 replicas, 1 updated and 3 ready, 3 desired",
+		},
+		{
+			name:     "updated replicas not ready",
+			kind:     "KubeadmControlPlane",
+			desired:  3,
+			status:   map[string]interface{}{"replicas": int64(3), "updatedReplicas": int64(3), "readyReplicas": int64(2)},
+			expected: "KubeadmControlPlane md-0 has 3 replicas, 3 updated and 2 ready, 3 desired",
+		},
+		{
+			name:     "status not reported yet",
+			kind:     "MachineDeployment",
+			desired:  1,
+			status:   map[string]interface{}{},
+			expected: "MachineDeployment md-0 has 0 replicas, 0 updated and 0 ready, 1 desired",
+		},
+		{
+			name:     "malformed status",
+			kind:     "MachineDeployment",
+			desired:  1,
+			status:   map[string]interface{}{"replicas": "one"},
+			expected: ".status.replicas accessor error: one is of the type string, expected int64",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			u := capiObject(tc.kind, "md-0", map[string]interface{}{
+				"spec":   map[string]interface{}{"replicas": tc.desired},
+				"status": tc.status,
+			})
+
+			err := clusterapi.CheckReplicasUpdated(*u)
+			switch {
+			case tc.expected == "" && err != nil:
+				t.Errorf("expected replicas to be updated, got %v", err)
+			case tc.expected != "" && (err == nil || err.Error() != tc.expected):
+				t.Errorf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
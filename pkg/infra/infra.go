@@ -3,7 +3,10 @@ package infra
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/rest"
+
+	"github.com/filariow/mctest/pkg/kube"
 )
 
 type ClusterProvisioner interface {
@@ -21,3 +24,40 @@ type ClusterProvisioner interface {
 	// Wait for clusters to be provisioned
 	WaitForProvisionedClusters(ctx context.Context) error
 }
+
+// ClusterNamesProvider is implemented by provisioners that know
+// the names of their clusters before provisioning them.
+type ClusterNamesProvider interface {
+	// Returns the names of the clusters managed by the provisioner
+	ClusterNames() []string
+}
+
+// NamespacedProvisioner is implemented by provisioners whose clusters
+// are scoped to a namespace of a host cluster.
+type NamespacedProvisioner interface {
+	// Returns the namespace the clusters are scoped to
+	GetNamespace() string
+}
+
+// CredentialSourcesProvider is implemented by provisioners that can provide
+// credentials that are refreshed before they expire.
+type CredentialSourcesProvider interface {
+	// Returns the credential sources for all provisioned clusters
+	GetAllCredentialSources(ctx context.Context) (map[string]kube.CredentialSource, error)
+}
+
+// ClusterUpgrader is implemented by provisioners that can upgrade
+// the Kubernetes version of the clusters they provisioned.
+type ClusterUpgrader interface {
+	// Upgrades the given cluster to the given Kubernetes version and waits for the upgrade to complete
+	Upgrade(ctx context.Context, clusterName, version string) error
+}
+
+// MachineManager is implemented by provisioners that can scale the worker pools
+// of the clusters they provisioned and simulate machine failures.
+type MachineManager interface {
+	// Scales the given worker pool to the given number of replicas and waits for the cluster's nodes to converge
+	ScaleWorkers(ctx context.Context, clusterName, pool string, replicas int) error
+	// Deletes a machine matching the given selector and waits for the cluster's nodes to converge
+	DeleteMachine(ctx context.Context, clusterName string, selector labels.Selector) error
+}
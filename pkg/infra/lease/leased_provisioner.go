@@ -0,0 +1,336 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+const (
+	leaseNamePrefix   string = "mctest-cluster-"
+	leaseClusterLabel string = "mctest.io/cluster"
+
+	DefaultLeaseDuration time.Duration = 1 * time.Minute
+	DefaultRenewInterval time.Duration = 20 * time.Second
+	DefaultRetryInterval time.Duration = 5 * time.Second
+)
+
+var (
+	ErrClusterNamesUnknown error = fmt.Errorf("error wrapped provisioner does not provide cluster names")
+	ErrLeaseHeld           error = fmt.Errorf("error lease held by another holder")
+	ErrLeaseLost           error = fmt.Errorf("error lease lost while clusters were in use")
+)
+
+var _ infra.ClusterProvisioner = &LeasedProvisioner{}
+
+// LeasedProvisioner wraps a ClusterProvisioner taking a coordination.k8s.io/v1 Lease
+// in the management cluster for each of the wrapped provisioner's clusters.
+// Leases are acquired by Provision, renewed in background and released by Unprovision.
+// Leases that are not renewed in time can be taken over by other holders:
+// such a loss is reported by Unprovision.
+type LeasedProvisioner struct {
+	infra.ClusterProvisioner
+
+	// Client for the cluster the Leases are created in
+	Kubernetes kube.Client
+	// Namespace the Leases are created in
+	Namespace string
+	// Identity of the lease holder
+	Holder string
+
+	LeaseDuration time.Duration
+	RenewInterval time.Duration
+	RetryInterval time.Duration
+
+	mu          sync.Mutex
+	leases      []string
+	renewed     map[string]time.Time
+	lost        map[string]error
+	renewCancel context.CancelFunc
+	renewDone   chan struct{}
+}
+
+// NewLeasedProvisioner wraps the given provisioner. The provisioner is required
+// to implement infra.ClusterNamesProvider.
+// If holder is empty, DefaultHolderIdentity is used.
+func NewLeasedProvisioner(
+	kubernetes kube.Client,
+	namespace string,
+	holder string,
+	provisioner infra.ClusterProvisioner,
+) *LeasedProvisioner {
+	if holder == "" {
+		holder = DefaultHolderIdentity()
+	}
+
+	return &LeasedProvisioner{
+		ClusterProvisioner: provisioner,
+		Kubernetes:         kubernetes,
+		Namespace:          namespace,
+		Holder:             holder,
+		LeaseDuration:      DefaultLeaseDuration,
+		RenewInterval:      DefaultRenewInterval,
+		RetryInterval:      DefaultRetryInterval,
+	}
+}
+
+// DefaultHolderIdentity returns an identity built from hostname, process id
+// and a random suffix, so that provisioners in the same process do not share it
+func DefaultHolderIdentity() string {
+	h, err := os.Hostname()
+	if err != nil {
+		h = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", h, os.Getpid(), rand.String(5))
+}
+
+// Provision acquires the leases for the wrapped provisioner's clusters,
+// waiting for them to be released or to expire, and then provisions the clusters.
+// Waiting honours ctx's deadline.
+func (p *LeasedProvisioner) Provision(ctx context.Context) error {
+	np, ok := p.ClusterProvisioner.(infra.ClusterNamesProvider)
+	if !ok {
+		return ErrClusterNamesUnknown
+	}
+
+	// acquire leases in a stable order to avoid deadlocks among holders
+	nn := np.ClusterNames()
+	sort.Strings(nn)
+	for _, n := range nn {
+		if err := poll.Do(ctx, p.RetryInterval, func(ctx context.Context) error {
+			return p.acquire(ctx, n)
+		}); err != nil {
+			return errors.Join(fmt.Errorf("error acquiring lease for cluster %s: %w", n, err), p.releaseAll(context.Background()))
+		}
+
+		p.mu.Lock()
+		p.leases = append(p.leases, n)
+		if p.renewed == nil {
+			p.renewed = map[string]time.Time{}
+		}
+		p.renewed[n] = time.Now()
+		p.mu.Unlock()
+	}
+
+	p.startRenewing()
+
+	if err := p.ClusterProvisioner.Provision(ctx); err != nil {
+		return errors.Join(err, p.releaseAll(context.Background()))
+	}
+	return nil
+}
+
+// Unprovision unprovisions the wrapped provisioner's clusters and releases the leases.
+// It returns ErrLeaseLost if any lease was lost since Provision.
+func (p *LeasedProvisioner) Unprovision(ctx context.Context) error {
+	err := p.ClusterProvisioner.Unprovision(ctx)
+	return errors.Join(err, p.releaseAll(ctx))
+}
+
+func (p *LeasedProvisioner) acquire(ctx context.Context, cluster string) error {
+	now := metav1.NowMicro()
+	ds := int32(p.LeaseDuration.Seconds())
+
+	l := coordinationv1.Lease{}
+	t := types.NamespacedName{Namespace: p.Namespace, Name: leaseName(cluster)}
+	if err := p.Kubernetes.Get(ctx, t, &l, &client.GetOptions{}); err != nil {
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+
+		// create lease
+		l = coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      t.Name,
+				Namespace: t.Namespace,
+				Labels:    map[string]string{leaseClusterLabel: cluster},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &p.Holder,
+				LeaseDurationSeconds: &ds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		return p.Kubernetes.Create(ctx, &l, &client.CreateOptions{})
+	}
+
+	// take over the lease if it is held by us, released, or expired
+	if h := l.Spec.HolderIdentity; h != nil && *h != "" && *h != p.Holder && !isExpired(l, now.Time) {
+		return fmt.Errorf("%w: cluster %s, holder %s, renewed at %v", ErrLeaseHeld, cluster, *h, l.Spec.RenewTime)
+	}
+
+	if h := l.Spec.HolderIdentity; h == nil || *h != p.Holder {
+		tr := int32(0)
+		if l.Spec.LeaseTransitions != nil {
+			tr = *l.Spec.LeaseTransitions + 1
+		}
+		l.Spec.LeaseTransitions = &tr
+		l.Spec.AcquireTime = &now
+	}
+	l.Spec.HolderIdentity = &p.Holder
+	l.Spec.LeaseDurationSeconds = &ds
+	l.Spec.RenewTime = &now
+
+	// optimistic concurrency on resourceVersion prevents two holders from taking over the same lease
+	return p.Kubernetes.Update(ctx, &l, &client.UpdateOptions{})
+}
+
+func (p *LeasedProvisioner) renew(ctx context.Context, cluster string) error {
+	l := coordinationv1.Lease{}
+	t := types.NamespacedName{Namespace: p.Namespace, Name: leaseName(cluster)}
+	if err := p.Kubernetes.Get(ctx, t, &l, &client.GetOptions{}); err != nil {
+		return err
+	}
+
+	if h := l.Spec.HolderIdentity; h == nil || *h != p.Holder {
+		return fmt.Errorf("%w: lease for cluster %s has been taken over", ErrLeaseHeld, cluster)
+	}
+
+	now := metav1.NowMicro()
+	l.Spec.RenewTime = &now
+	return p.Kubernetes.Update(ctx, &l, &client.UpdateOptions{})
+}
+
+func (p *LeasedProvisioner) release(ctx context.Context, cluster string) error {
+	l := coordinationv1.Lease{}
+	t := types.NamespacedName{Namespace: p.Namespace, Name: leaseName(cluster)}
+	if err := p.Kubernetes.Get(ctx, t, &l, &client.GetOptions{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if h := l.Spec.HolderIdentity; h == nil || *h != p.Holder {
+		// taken over by another holder
+		return nil
+	}
+
+	rv := l.GetResourceVersion()
+	if err := p.Kubernetes.Delete(ctx, &l, &client.DeleteOptions{Preconditions: &metav1.Preconditions{ResourceVersion: &rv}}); err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (p *LeasedProvisioner) startRenewing() {
+	rctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	p.mu.Lock()
+	p.renewCancel, p.renewDone = cancel, done
+	p.mu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		tk := time.NewTicker(p.RenewInterval)
+		defer tk.Stop()
+
+		for {
+			select {
+			case <-rctx.Done():
+				return
+			case <-tk.C:
+				p.renewAll(rctx)
+			}
+		}
+	}()
+}
+
+// renewAll renews the held leases. A lease is lost if it has been taken over
+// or has not been renewed for longer than its duration: lost leases are not
+// renewed anymore and are reported when released.
+func (p *LeasedProvisioner) renewAll(ctx context.Context) {
+	p.mu.Lock()
+	ll := []string{}
+	for _, n := range p.leases {
+		if _, ok := p.lost[n]; !ok {
+			ll = append(ll, n)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, n := range ll {
+		err := p.renew(ctx, n)
+
+		p.mu.Lock()
+		switch {
+		case err == nil:
+			p.renewed[n] = time.Now()
+		case ctx.Err() != nil:
+			// renewal stopped
+		case errors.Is(err, ErrLeaseHeld) || time.Since(p.renewed[n]) >= p.LeaseDuration:
+			log.Printf("lease for cluster %s lost: %v", n, err)
+			if p.lost == nil {
+				p.lost = map[string]error{}
+			}
+			p.lost[n] = fmt.Errorf("%w: cluster %s: %w", ErrLeaseLost, n, err)
+		default:
+			log.Printf("error renewing lease for cluster %s, retrying: %v", n, err)
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *LeasedProvisioner) stopRenewing() {
+	p.mu.Lock()
+	cancel, done := p.renewCancel, p.renewDone
+	p.renewCancel, p.renewDone = nil, nil
+	p.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (p *LeasedProvisioner) releaseAll(ctx context.Context) error {
+	p.stopRenewing()
+
+	p.mu.Lock()
+	ll, lost := p.leases, p.lost
+	p.leases, p.renewed, p.lost = nil, nil, nil
+	p.mu.Unlock()
+
+	errs := []error{}
+	for _, n := range ll {
+		if err, ok := lost[n]; ok {
+			errs = append(errs, err)
+		}
+		if err := p.release(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("error releasing lease for cluster %s: %w", n, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isExpired(l coordinationv1.Lease, now time.Time) bool {
+	if l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	d := time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second
+	return l.Spec.RenewTime.Add(d).Before(now)
+}
+
+func leaseName(cluster string) string {
+	return leaseNamePrefix + cluster
+}
@@ -0,0 +1,279 @@
+package lease_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/filariow/mctest/pkg/infra/lease"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+const namespace string = "leases"
+
+// fakeKube is a kube.Client serving Leases from a fake client
+type fakeKube struct {
+	kube.Client
+
+	cli client.WithWatch
+}
+
+func (k *fakeKube) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return k.cli.Get(ctx, key, obj, opts...)
+}
+
+func (k *fakeKube) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return k.cli.Create(ctx, obj, opts...)
+}
+
+func (k *fakeKube) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return k.cli.Update(ctx, obj, opts...)
+}
+
+func (k *fakeKube) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return k.cli.Delete(ctx, obj, opts...)
+}
+
+// fakeProvisioner records the calls to Provision and Unprovision
+type fakeProvisioner struct {
+	names []string
+
+	provisioned   bool
+	unprovisioned bool
+}
+
+func (p *fakeProvisioner) GetAllAdminKubeconfigs(context.Context) (map[string]rest.Config, error) {
+	return nil, nil
+}
+
+func (p *fakeProvisioner) Provision(context.Context) error {
+	p.provisioned = true
+	return nil
+}
+
+func (p *fakeProvisioner) NumClustersProvisionedInProvisionRound() int {
+	return len(p.names)
+}
+
+func (p *fakeProvisioner) Unprovision(context.Context) error {
+	p.unprovisioned = true
+	return nil
+}
+
+func (p *fakeProvisioner) WaitForProvisionedClusters(context.Context) error {
+	return nil
+}
+
+func (p *fakeProvisioner) ClusterNames() []string {
+	return p.names
+}
+
+func newLeasedProvisioner(k kube.Client, holder string, names ...string) (*lease.LeasedProvisioner, *fakeProvisioner) {
+	fp := &fakeProvisioner{names: names}
+	p := lease.NewLeasedProvisioner(k, namespace, holder, fp)
+	p.RetryInterval = 20 * time.Millisecond
+	p.RenewInterval = 20 * time.Millisecond
+	return p, fp
+}
+
+func heldLease(cluster, holder string, renewed time.Time) *coordinationv1.Lease {
+	d, tr := int32(60), int32(0)
+	r := metav1.NewMicroTime(renewed)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "mctest-cluster-" + cluster},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &d,
+			AcquireTime:          &r,
+			RenewTime:            &r,
+			LeaseTransitions:     &tr,
+		},
+	}
+}
+
+func getLease(t *testing.T, k *fakeKube, cluster string) (*coordinationv1.Lease, error) {
+	t.Helper()
+
+	l := coordinationv1.Lease{}
+	err := k.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "mctest-cluster-" + cluster}, &l)
+	return &l, err
+}
+
+func holder(l *coordinationv1.Lease) string {
+	if l.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *l.Spec.HolderIdentity
+}
+
+func newFakeKube(objs ...client.Object) *fakeKube {
+	return &fakeKube{cli: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()}
+}
+
+func Test_DefaultHolderIdentity(t *testing.T) {
+	if a, b := lease.DefaultHolderIdentity(), lease.DefaultHolderIdentity(); a == b {
+		t.Errorf("expected distinct identities in the same process, got %s twice", a)
+	}
+}
+
+func Test_LeasedProvisioner_Acquire(t *testing.T) {
+	k := newFakeKube()
+	p, fp := newLeasedProvisioner(k, "me", "two", "one")
+
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Unprovision(context.Background())
+
+	if !fp.provisioned {
+		t.Error("expected the wrapped provisioner to be provisioned")
+	}
+	for _, n := range []string{"one", "two"} {
+		l, err := getLease(t, k, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := holder(l); h != "me" {
+			t.Errorf("expected lease for cluster %s to be held by me, got %q", n, h)
+		}
+	}
+}
+
+func Test_LeasedProvisioner_Contention(t *testing.T) {
+	k := newFakeKube(heldLease("one", "other", time.Now()))
+	p, fp := newLeasedProvisioner(k, "me", "one")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := p.Provision(ctx)
+	if !errors.Is(err, lease.ErrLeaseHeld) {
+		t.Errorf("expected ErrLeaseHeld, got %v", err)
+	}
+	if fp.provisioned {
+		t.Error("expected the wrapped provisioner not to be provisioned")
+	}
+
+	l, err := getLease(t, k, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := holder(l); h != "other" {
+		t.Errorf("expected lease to be still held by other, got %q", h)
+	}
+}
+
+func Test_LeasedProvisioner_WaitHonoursDeadline(t *testing.T) {
+	k := newFakeKube(heldLease("one", "other", time.Now()))
+	p, _ := newLeasedProvisioner(k, "me", "one")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	s := time.Now()
+	err := p.Provision(ctx)
+	if !errors.Is(err, poll.ErrPollerTimeout) {
+		t.Errorf("expected ErrPollerTimeout, got %v", err)
+	}
+	if d := time.Since(s); d > time.Second {
+		t.Errorf("expected Provision to return at the deadline, returned after %v", d)
+	}
+}
+
+func Test_LeasedProvisioner_ExpiryTakeOver(t *testing.T) {
+	k := newFakeKube(heldLease("one", "other", time.Now().Add(-2*time.Minute)))
+	p, fp := newLeasedProvisioner(k, "me", "one")
+
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Unprovision(context.Background())
+
+	if !fp.provisioned {
+		t.Error("expected the wrapped provisioner to be provisioned")
+	}
+
+	l, err := getLease(t, k, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := holder(l); h != "me" {
+		t.Errorf("expected expired lease to be taken over, got holder %q", h)
+	}
+	if tr := l.Spec.LeaseTransitions; tr == nil {
+		t.Error("expected lease transitions to be set")
+	} else if *tr != 1 {
+		t.Errorf("expected 1 lease transition, got %d", *tr)
+	}
+}
+
+func Test_LeasedProvisioner_Release(t *testing.T) {
+	k := newFakeKube()
+	p, fp := newLeasedProvisioner(k, "me", "one")
+
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unprovision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fp.unprovisioned {
+		t.Error("expected the wrapped provisioner to be unprovisioned")
+	}
+	if _, err := getLease(t, k, "one"); !kerrors.IsNotFound(err) {
+		t.Errorf("expected lease to be released, got %v", err)
+	}
+
+	// the released lease can be acquired by another holder
+	o, _ := newLeasedProvisioner(k, "other", "one")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := o.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Unprovision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_LeasedProvisioner_Lost(t *testing.T) {
+	k := newFakeKube()
+	p, _ := newLeasedProvisioner(k, "me", "one")
+
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// another holder takes over the lease
+	l, err := getLease(t, k, "one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := "other"
+	l.Spec.HolderIdentity = &o
+	if err := k.Update(context.Background(), l); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the renewal to fail
+	time.Sleep(100 * time.Millisecond)
+
+	if err := p.Unprovision(context.Background()); !errors.Is(err, lease.ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+	if _, err := getLease(t, k, "one"); err != nil {
+		t.Errorf("expected lease held by other not to be released, got %v", err)
+	}
+}
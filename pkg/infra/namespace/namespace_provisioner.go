@@ -0,0 +1,271 @@
+package namespace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+const (
+	DefaultServiceAccountName string        = "test-runner"
+	DefaultTokenExpiration    time.Duration = 1 * time.Hour
+)
+
+// CredentialsMode defines how clients authenticate as the ServiceAccount
+type CredentialsMode string
+
+const (
+	// Clients use tokens minted for the ServiceAccount
+	ServiceAccountTokenCredentials CredentialsMode = "ServiceAccountToken"
+	// Clients use the host cluster's credentials impersonating the ServiceAccount
+	ImpersonationCredentials CredentialsMode = "Impersonation"
+)
+
+var _ infra.ClusterProvisioner = &NamespaceProvisioner{}
+var _ infra.ClusterNamesProvider = &NamespaceProvisioner{}
+var _ infra.NamespacedProvisioner = &NamespaceProvisioner{}
+var _ infra.CredentialSourcesProvider = &NamespaceProvisioner{}
+
+// AdminRules grants full access to all the namespaced resources of the namespace
+var AdminRules = []rbacv1.PolicyRule{
+	{
+		Verbs:     []string{"*"},
+		APIGroups: []string{"*"},
+		Resources: []string{"*"},
+	},
+}
+
+// NamespaceProvisioner provisions a "virtual cluster" made of a namespace
+// in a host cluster and a ServiceAccount with permissions on it.
+// The kubeconfig returned by GetAllAdminKubeconfigs authenticates as the ServiceAccount.
+type NamespaceProvisioner struct {
+	// Client for the host cluster
+	Kubernetes kube.Client
+	// Name of the namespace to provision
+	Namespace string
+	// Labels to set on the namespace
+	Labels map[string]string
+
+	// Name of the ServiceAccount the kubeconfigs authenticate as
+	ServiceAccountName string
+	// How clients authenticate as the ServiceAccount
+	Credentials CredentialsMode
+	// Rules granted to the ServiceAccount through a Role in the namespace
+	Rules []rbacv1.PolicyRule
+	// Existing ClusterRoles granted to the ServiceAccount through RoleBindings in the namespace, e.g. edit or view
+	ClusterRoles []string
+	// Optional ResourceQuota applied to the namespace
+	Quota *corev1.ResourceQuotaSpec
+	// Expiration of the ServiceAccount tokens
+	TokenExpiration time.Duration
+}
+
+// NewNamespaceProvisioner returns a provisioner for the given namespace
+// granting AdminRules to the DefaultServiceAccountName ServiceAccount.
+// Clients authenticate with ServiceAccount tokens.
+func NewNamespaceProvisioner(kubernetes kube.Client, namespace string, labels map[string]string) *NamespaceProvisioner {
+	return &NamespaceProvisioner{
+		Kubernetes:         kubernetes,
+		Namespace:          namespace,
+		Labels:             labels,
+		ServiceAccountName: DefaultServiceAccountName,
+		Credentials:        ServiceAccountTokenCredentials,
+		Rules:              AdminRules,
+		TokenExpiration:    DefaultTokenExpiration,
+	}
+}
+
+// Provision creates the namespace, the ServiceAccount, the RBAC resources and the quota.
+// If any of them can not be created, the namespace is deleted along with the resources created in it.
+func (p *NamespaceProvisioner) Provision(ctx context.Context) error {
+	ns := corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   p.Namespace,
+			Labels: p.Labels,
+		},
+	}
+	if err := p.Kubernetes.Create(ctx, &ns, &client.CreateOptions{}); err != nil {
+		return fmt.Errorf("error creating namespace %s: %w", p.Namespace, err)
+	}
+
+	oo := []client.Object{
+		&corev1.ServiceAccount{ObjectMeta: p.objectMeta(p.ServiceAccountName)},
+	}
+
+	if len(p.Rules) > 0 {
+		oo = append(oo,
+			&rbacv1.Role{
+				ObjectMeta: p.objectMeta(p.ServiceAccountName),
+				Rules:      p.Rules,
+			},
+			p.roleBinding(p.ServiceAccountName, "Role", p.ServiceAccountName),
+		)
+	}
+
+	for _, cr := range p.ClusterRoles {
+		n := fmt.Sprintf("%s-%s", p.ServiceAccountName, cr)
+		oo = append(oo, p.roleBinding(n, "ClusterRole", cr))
+	}
+
+	if p.Quota != nil {
+		oo = append(oo, &corev1.ResourceQuota{
+			ObjectMeta: p.objectMeta(p.Namespace),
+			Spec:       *p.Quota,
+		})
+	}
+
+	for _, o := range oo {
+		if err := p.Kubernetes.Create(ctx, o, &client.CreateOptions{}); err != nil {
+			err = fmt.Errorf("error creating %T %s/%s: %w", o, o.GetNamespace(), o.GetName(), err)
+			if derr := p.Kubernetes.Delete(ctx, &ns, &client.DeleteOptions{}); derr != nil && !kerrors.IsNotFound(derr) {
+				return errors.Join(err, fmt.Errorf("error deleting namespace %s: %w", p.Namespace, derr))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForProvisionedClusters waits for the namespace to be Active
+// and the ServiceAccount to be available
+func (p *NamespaceProvisioner) WaitForProvisionedClusters(ctx context.Context) error {
+	return poll.Do(ctx, 2*time.Second, func(ctx context.Context) error {
+		ns := corev1.Namespace{}
+		if err := p.Kubernetes.Get(ctx, types.NamespacedName{Name: p.Namespace}, &ns, &client.GetOptions{}); err != nil {
+			return err
+		}
+		if ns.Status.Phase != corev1.NamespaceActive {
+			return fmt.Errorf("namespace %s is in phase %s", p.Namespace, ns.Status.Phase)
+		}
+
+		sa := corev1.ServiceAccount{}
+		t := types.NamespacedName{Namespace: p.Namespace, Name: p.ServiceAccountName}
+		return p.Kubernetes.Get(ctx, t, &sa, &client.GetOptions{})
+	})
+}
+
+// GetAllAdminKubeconfigs returns a kubeconfig authenticating as the ServiceAccount, indexed by the namespace name.
+// With ServiceAccountTokenCredentials, the kubeconfig contains a freshly minted token
+// that is not refreshed: use GetAllCredentialSources for long running clients.
+func (p *NamespaceProvisioner) GetAllAdminKubeconfigs(ctx context.Context) (map[string]rest.Config, error) {
+	src, err := p.credentialSource()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg *rest.Config
+	switch s := src.(type) {
+	case *kube.ServiceAccountTokenSource:
+		tk, err := s.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cfg = rest.AnonymousClientConfig(p.Kubernetes.RESTConfig())
+		cfg.BearerToken = tk
+	default:
+		if cfg, err = src.RESTConfig(); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]rest.Config{p.Namespace: *cfg}, nil
+}
+
+// GetAllCredentialSources returns the credential source for the ServiceAccount, indexed by the namespace name
+func (p *NamespaceProvisioner) GetAllCredentialSources(ctx context.Context) (map[string]kube.CredentialSource, error) {
+	src, err := p.credentialSource()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]kube.CredentialSource{p.Namespace: src}, nil
+}
+
+func (p *NamespaceProvisioner) credentialSource() (kube.CredentialSource, error) {
+	switch p.Credentials {
+	case ServiceAccountTokenCredentials, "":
+		return kube.NewServiceAccountTokenSource(p.Kubernetes.RESTConfig(), p.Namespace, p.ServiceAccountName, p.TokenExpiration)
+	case ImpersonationCredentials:
+		return kube.NewServiceAccountImpersonationSource(p.Kubernetes.RESTConfig(), p.Namespace, p.ServiceAccountName), nil
+	default:
+		return nil, fmt.Errorf("unsupported credentials mode %s", p.Credentials)
+	}
+}
+
+// Returns the name of the provisioned namespace
+func (p *NamespaceProvisioner) ClusterNames() []string {
+	return []string{p.Namespace}
+}
+
+// Returns the name of the provisioned namespace
+func (p *NamespaceProvisioner) GetNamespace() string {
+	return p.Namespace
+}
+
+// Only one namespace is provisioned
+func (p *NamespaceProvisioner) NumClustersProvisionedInProvisionRound() int {
+	return 1
+}
+
+// Unprovision deletes the namespace, and so all the resources in it,
+// and waits for it to be removed
+func (p *NamespaceProvisioner) Unprovision(ctx context.Context) error {
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: p.Namespace}}
+	if err := p.Kubernetes.Delete(ctx, &ns, &client.DeleteOptions{}); err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("error deleting namespace %s: %w", p.Namespace, err)
+	}
+
+	log.Printf("waiting for namespace %s to be deleted", p.Namespace)
+	return poll.Do(ctx, 2*time.Second, func(ctx context.Context) error {
+		err := p.Kubernetes.Get(ctx, types.NamespacedName{Name: p.Namespace}, &ns, &client.GetOptions{})
+		switch {
+		case kerrors.IsNotFound(err):
+			return nil
+		case err != nil:
+			return err
+		default:
+			return fmt.Errorf("namespace %s still exists", p.Namespace)
+		}
+	})
+}
+
+func (p *NamespaceProvisioner) objectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: p.Namespace,
+		Labels:    p.Labels,
+	}
+}
+
+func (p *NamespaceProvisioner) roleBinding(name, roleKind, roleName string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: p.objectMeta(name),
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      p.ServiceAccountName,
+				Namespace: p.Namespace,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     roleKind,
+			Name:     roleName,
+		},
+	}
+}
@@ -0,0 +1,233 @@
+package namespace_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/filariow/mctest/pkg/infra/namespace"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeKube is a kube.Client serving objects from a fake client,
+// whose rest.Config points to a server minting ServiceAccount tokens
+type fakeKube struct {
+	kube.Client
+
+	cli client.WithWatch
+	cfg *rest.Config
+}
+
+func newFakeKube(t *testing.T, funcs interceptor.Funcs) *fakeKube {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/test/serviceaccounts/test-runner/token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(authenticationv1.TokenRequest{
+			TypeMeta: metav1.TypeMeta{APIVersion: "authentication.k8s.io/v1", Kind: "TokenRequest"},
+			Status: authenticationv1.TokenRequestStatus{
+				Token:               "minted",
+				ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Hour)),
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	return &fakeKube{
+		cli: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithInterceptorFuncs(funcs).Build(),
+		cfg: &rest.Config{Host: srv.URL, BearerToken: "admin"},
+	}
+}
+
+func (k *fakeKube) RESTConfig() *rest.Config {
+	return rest.CopyConfig(k.cfg)
+}
+
+func (k *fakeKube) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return k.cli.Get(ctx, key, obj, opts...)
+}
+
+func (k *fakeKube) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return k.cli.Create(ctx, obj, opts...)
+}
+
+func (k *fakeKube) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return k.cli.Delete(ctx, obj, opts...)
+}
+
+func exists(t *testing.T, k *fakeKube, obj client.Object, name string) bool {
+	t.Helper()
+
+	ns := "test"
+	if _, ok := obj.(*corev1.Namespace); ok {
+		ns = ""
+	}
+	err := k.Get(context.Background(), types.NamespacedName{Namespace: ns, Name: name}, obj)
+	switch {
+	case kerrors.IsNotFound(err):
+		return false
+	case err != nil:
+		t.Fatal(err)
+	}
+	return true
+}
+
+func Test_NamespaceProvisioner_Provision(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resources are created", func(t *testing.T) {
+		k := newFakeKube(t, interceptor.Funcs{})
+		p := namespace.NewNamespaceProvisioner(k, "test", map[string]string{"mctest": "true"})
+		p.ClusterRoles = []string{"view"}
+		p.Quota = &corev1.ResourceQuotaSpec{}
+
+		if err := p.Provision(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		ns := corev1.Namespace{}
+		if !exists(t, k, &ns, "test") {
+			t.Fatal("expected namespace test to be created")
+		}
+		if ns.Labels["mctest"] != "true" {
+			t.Errorf("expected namespace to be labeled, got %v", ns.Labels)
+		}
+		for _, o := range []struct {
+			obj  client.Object
+			name string
+		}{
+			{&corev1.ServiceAccount{}, "test-runner"},
+			{&rbacv1.Role{}, "test-runner"},
+			{&rbacv1.RoleBinding{}, "test-runner"},
+			{&rbacv1.RoleBinding{}, "test-runner-view"},
+			{&corev1.ResourceQuota{}, "test"},
+		} {
+			if !exists(t, k, o.obj, o.name) {
+				t.Errorf("expected %T %s to be created", o.obj, o.name)
+			}
+		}
+
+		rb := rbacv1.RoleBinding{}
+		exists(t, k, &rb, "test-runner-view")
+		if rb.RoleRef.Kind != "ClusterRole" || rb.RoleRef.Name != "view" {
+			t.Errorf("expected RoleBinding to ClusterRole view, got %v", rb.RoleRef)
+		}
+	})
+
+	t.Run("namespace is deleted on failure", func(t *testing.T) {
+		k := newFakeKube(t, interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if _, ok := obj.(*rbacv1.RoleBinding); ok {
+					return kerrors.NewForbidden(rbacv1.Resource("rolebindings"), obj.GetName(), errors.New("escalation"))
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		})
+		p := namespace.NewNamespaceProvisioner(k, "test", nil)
+
+		if err := p.Provision(ctx); !kerrors.IsForbidden(err) {
+			t.Fatalf("expected Forbidden, got %v", err)
+		}
+		if exists(t, k, &corev1.Namespace{}, "test") {
+			t.Error("expected namespace test to be deleted")
+		}
+	})
+}
+
+func Test_NamespaceProvisioner_Unprovision(t *testing.T) {
+	ctx := context.Background()
+	k := newFakeKube(t, interceptor.Funcs{})
+	p := namespace.NewNamespaceProvisioner(k, "test", nil)
+
+	if err := p.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Unprovision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exists(t, k, &corev1.Namespace{}, "test") {
+		t.Error("expected namespace test to be deleted")
+	}
+
+	// a missing namespace is not an error
+	if err := p.Unprovision(ctx); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_NamespaceProvisioner_GetAllAdminKubeconfigs(t *testing.T) {
+	ctx := context.Background()
+
+	tt := map[string]struct {
+		credentials namespace.CredentialsMode
+		token       string
+		impersonate string
+		err         bool
+	}{
+		"service account token": {
+			credentials: namespace.ServiceAccountTokenCredentials,
+			token:       "minted",
+		},
+		"default is service account token": {
+			token: "minted",
+		},
+		"impersonation": {
+			credentials: namespace.ImpersonationCredentials,
+			token:       "admin",
+			impersonate: "system:serviceaccount:test:test-runner",
+		},
+		"unsupported": {
+			credentials: "Unsupported",
+			err:         true,
+		},
+	}
+
+	for n, tc := range tt {
+		t.Run(n, func(t *testing.T) {
+			p := namespace.NewNamespaceProvisioner(newFakeKube(t, interceptor.Funcs{}), "test", nil)
+			p.Credentials = tc.credentials
+
+			cc, err := p.GetAllAdminKubeconfigs(ctx)
+			if tc.err {
+				if err == nil {
+					t.Error("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, ok := cc["test"]
+			if !ok || len(cc) != 1 {
+				t.Fatalf("expected one kubeconfig for namespace test, got %v", cc)
+			}
+			if cfg.BearerToken != tc.token {
+				t.Errorf("expected token %q, got %q", tc.token, cfg.BearerToken)
+			}
+			if cfg.Impersonate.UserName != tc.impersonate {
+				t.Errorf("expected to impersonate %q, got %q", tc.impersonate, cfg.Impersonate.UserName)
+			}
+		})
+	}
+}
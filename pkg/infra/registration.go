@@ -0,0 +1,224 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const (
+	DefaultRegistrationServiceAccountName      string        = "mctest-registration"
+	DefaultRegistrationServiceAccountNamespace string        = "default"
+	DefaultRegistrationClusterRole             string        = "cluster-admin"
+	DefaultRegistrationSecretKey               string        = "kubeconfig"
+	DefaultRegistrationTokenExpiration         time.Duration = 24 * time.Hour
+)
+
+// RegistrationOptions configures how a source cluster is registered in a target one
+type RegistrationOptions struct {
+	// ServiceAccount created in the source cluster.
+	// The namespace is ignored for clients scoped to namespaces.
+	ServiceAccountName      string
+	ServiceAccountNamespace string
+	// ClusterRole granted to the ServiceAccount in the source cluster
+	ClusterRole string
+	// Expiration of the ServiceAccount token
+	TokenExpiration time.Duration
+
+	// Secret created in the target cluster
+	SecretName      string
+	SecretNamespace string
+	// Key of the Secret's data containing the kubeconfig
+	SecretKey string
+}
+
+// DefaultRegistrationOptions returns the options for registering a cluster
+// as cluster-admin in the given Secret of the target cluster
+func DefaultRegistrationOptions(secretName, secretNamespace string) RegistrationOptions {
+	return RegistrationOptions{
+		ServiceAccountName:      DefaultRegistrationServiceAccountName,
+		ServiceAccountNamespace: DefaultRegistrationServiceAccountNamespace,
+		ClusterRole:             DefaultRegistrationClusterRole,
+		TokenExpiration:         DefaultRegistrationTokenExpiration,
+		SecretName:              secretName,
+		SecretNamespace:         secretNamespace,
+		SecretKey:               DefaultRegistrationSecretKey,
+	}
+}
+
+// Registration is a source cluster registered in a target cluster
+type Registration struct {
+	// Secret of the target cluster containing the kubeconfig
+	Secret *corev1.Secret
+
+	source  kube.Client
+	target  kube.Client
+	created []client.Object
+}
+
+// Unregister deletes the kubeconfig Secret from the target cluster, and the ServiceAccount
+// and bindings created in the source cluster by RegisterCluster
+func (r *Registration) Unregister(ctx context.Context) error {
+	errs := []error{}
+	if err := r.target.Delete(ctx, r.Secret, &client.DeleteOptions{}); client.IgnoreNotFound(err) != nil {
+		errs = append(errs, fmt.Errorf("error deleting Secret %s/%s from target cluster: %w", r.Secret.Namespace, r.Secret.Name, err))
+	}
+	return errors.Join(append(errs, r.unregisterSource(ctx))...)
+}
+
+// RegisterCluster creates a ServiceAccount bound to the configured ClusterRole in the source cluster,
+// mints a token for it and stores a kubeconfig using it in a Secret of the target cluster.
+// Registration fails if the Secret already exists, as it was not created for the registration.
+//
+// If the source client is scoped to namespaces, the ServiceAccount is created in the client's
+// namespace and the ClusterRole is granted by RoleBindings in the client's namespaces.
+func RegisterCluster(ctx context.Context, source, target kube.Client, opts RegistrationOptions) (*Registration, error) {
+	reg := &Registration{source: source, target: target}
+
+	// create service account and grant it the cluster role
+	nn := sourceNamespaces(source)
+	san := opts.ServiceAccountNamespace
+	if len(nn) > 0 {
+		san = nn[0]
+	}
+	sa := corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.ServiceAccountName,
+			Namespace: san,
+		},
+	}
+	oo := []client.Object{&sa}
+	ss := []rbacv1.Subject{
+		{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      sa.Name,
+			Namespace: sa.Namespace,
+		},
+	}
+	rr := rbacv1.RoleRef{
+		APIGroup: rbacv1.GroupName,
+		Kind:     "ClusterRole",
+		Name:     opts.ClusterRole,
+	}
+	if len(nn) == 0 {
+		oo = append(oo, &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s-%s", sa.Namespace, sa.Name, opts.ClusterRole)},
+			Subjects:   ss,
+			RoleRef:    rr,
+		})
+	}
+	for _, n := range nn {
+		oo = append(oo, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", sa.Name, opts.ClusterRole), Namespace: n},
+			Subjects:   ss,
+			RoleRef:    rr,
+		})
+	}
+	for _, o := range oo {
+		if err := source.Create(ctx, o, &client.CreateOptions{}); err != nil {
+			if !kerrors.IsAlreadyExists(err) {
+				return nil, errors.Join(
+					fmt.Errorf("error creating %T %s in source cluster: %w", o, o.GetName(), err),
+					reg.unregisterSource(ctx))
+			}
+			// pre-existing resources are not deleted on Unregister
+			continue
+		}
+		reg.created = append(reg.created, o)
+	}
+
+	s, err := storeKubeconfig(ctx, source, target, sa, opts)
+	if err != nil {
+		return nil, errors.Join(err, reg.unregisterSource(ctx))
+	}
+	reg.Secret = s
+	return reg, nil
+}
+
+func (r *Registration) unregisterSource(ctx context.Context) error {
+	errs := []error{}
+	for _, o := range r.created {
+		if err := r.source.Delete(ctx, o, &client.DeleteOptions{}); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("error deleting %T %s from source cluster: %w", o, o.GetName(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// storeKubeconfig mints a token for the ServiceAccount and stores a kubeconfig using it in the target cluster
+func storeKubeconfig(ctx context.Context, source, target kube.Client, sa corev1.ServiceAccount, opts RegistrationOptions) (*corev1.Secret, error) {
+	// mint token
+	cli, err := source.Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	es := int64(opts.TokenExpiration.Seconds())
+	r := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &es}}
+	r, err = cli.CoreV1().ServiceAccounts(sa.Namespace).CreateToken(ctx, sa.Name, r, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating token for ServiceAccount %s/%s: %w", sa.Namespace, sa.Name, err)
+	}
+
+	// build kubeconfig
+	sc := source.RESTConfig()
+	tls := rest.TLSClientConfig{
+		Insecure:   sc.Insecure,
+		ServerName: sc.ServerName,
+	}
+	if !sc.Insecure {
+		// the kubeconfig is used from other clusters: inline the CA file
+		tls.CAData = sc.CAData
+		if len(tls.CAData) == 0 && sc.CAFile != "" {
+			if tls.CAData, err = os.ReadFile(sc.CAFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	cfg := rest.Config{
+		Host:            sc.Host,
+		TLSClientConfig: tls,
+		BearerToken:     r.Status.Token,
+	}
+	kd, err := kube.SerializeKubeconfig(&cfg, &sa.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// store kubeconfig in target cluster
+	s := corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      opts.SecretName,
+			Namespace: opts.SecretNamespace,
+		},
+		Data: map[string][]byte{opts.SecretKey: kd},
+	}
+	if err := target.Create(ctx, &s, &client.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("error creating Secret %s/%s in target cluster: %w", s.Namespace, s.Name, err)
+	}
+	return &s, nil
+}
+
+// sourceNamespaces returns the namespaces a client is scoped to, if any
+func sourceNamespaces(source kube.Client) []string {
+	switch k := source.(type) {
+	case *kube.NamespacedKubernetes:
+		return []string{k.Namespace}
+	case *kube.MultiNamespacedKubernetes:
+		return k.Namespaces
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,267 @@
+package infra_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeRegistrationServer stores the created objects by path and mints tokens
+// for ServiceAccounts, recording the requests
+type fakeRegistrationServer struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests []string
+}
+
+func newFakeRegistrationServer(t *testing.T, paths ...string) (*fakeRegistrationServer, *rest.Config) {
+	s := &fakeRegistrationServer{objects: map[string][]byte{}}
+	for _, p := range paths {
+		s.objects[p] = []byte(`{}`)
+	}
+
+	srv := httptest.NewTLSServer(s)
+	t.Cleanup(srv.Close)
+	return s, &rest.Config{
+		Host:            srv.URL,
+		ContentConfig:   rest.ContentConfig{ContentType: "application/json"},
+		TLSClientConfig: rest.TLSClientConfig{CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})},
+	}
+}
+
+func (s *fakeRegistrationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case "/apis":
+		_ = e.Encode(metav1.APIGroupList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"},
+			Groups: []metav1.APIGroup{{
+				Name:             rbacv1.GroupName,
+				Versions:         []metav1.GroupVersionForDiscovery{{GroupVersion: "rbac.authorization.k8s.io/v1", Version: "v1"}},
+				PreferredVersion: metav1.GroupVersionForDiscovery{GroupVersion: "rbac.authorization.k8s.io/v1", Version: "v1"},
+			}},
+		})
+		return
+	case "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "secrets", Namespaced: true, Kind: "Secret", Verbs: []string{"create", "get", "update", "delete"}},
+				{Name: "serviceaccounts", Namespaced: true, Kind: "ServiceAccount", Verbs: []string{"create", "get", "delete"}},
+			},
+		})
+		return
+	case "/apis/rbac.authorization.k8s.io/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "rbac.authorization.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterrolebindings", Namespaced: false, Kind: "ClusterRoleBinding", Verbs: []string{"create", "get", "delete"}},
+				{Name: "rolebindings", Namespaced: true, Kind: "RoleBinding", Verbs: []string{"create", "get", "delete"}},
+			},
+		})
+		return
+	}
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	b, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/token"):
+		_ = e.Encode(authenticationv1.TokenRequest{
+			TypeMeta: metav1.TypeMeta{APIVersion: "authentication.k8s.io/v1", Kind: "TokenRequest"},
+			Status:   authenticationv1.TokenRequestStatus{Token: "minted"},
+		})
+	case r.Method == http.MethodPost:
+		o := metav1.PartialObjectMetadata{}
+		_ = json.Unmarshal(b, &o)
+		p := r.URL.Path + "/" + o.Name
+		if _, ok := s.objects[p]; ok {
+			w.WriteHeader(http.StatusConflict)
+			_ = e.Encode(metav1.Status{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}, Status: metav1.StatusFailure, Reason: metav1.StatusReasonAlreadyExists, Code: http.StatusConflict})
+			return
+		}
+		s.objects[p] = b
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(b)
+	case r.Method == http.MethodPut:
+		s.objects[r.URL.Path] = b
+		_, _ = w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		_ = e.Encode(metav1.Status{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}, Status: metav1.StatusSuccess})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeRegistrationServer) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return sets.List(sets.KeySet(s.objects))
+}
+
+func (s *fakeRegistrationServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+func (s *fakeRegistrationServer) object(t *testing.T, path string, obj interface{}) {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.objects[path]
+	if !ok {
+		t.Fatalf("expected object %s, found %v", path, sets.List(sets.KeySet(s.objects)))
+	}
+	if err := json.Unmarshal(b, obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_RegisterCluster(t *testing.T) {
+	ss, scfg := newFakeRegistrationServer(t)
+	scfg.ServerName = "example.com"
+	source, err := kube.New(scfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, tcfg := newFakeRegistrationServer(t)
+	target, err := kube.New(tcfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	opts := infra.DefaultRegistrationOptions("source-kubeconfig", "registered")
+	r, err := infra.RegisterCluster(ctx, source, target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ServiceAccount bound to the ClusterRole in the source cluster
+	crb := rbacv1.ClusterRoleBinding{}
+	ss.object(t, "/apis/rbac.authorization.k8s.io/v1/clusterrolebindings/default-mctest-registration-cluster-admin", &crb)
+	if len(crb.Subjects) != 1 || crb.Subjects[0].Namespace != "default" || crb.Subjects[0].Name != "mctest-registration" {
+		t.Errorf("expected ClusterRoleBinding to default/mctest-registration, got %v", crb.Subjects)
+	}
+	ss.object(t, "/api/v1/namespaces/default/serviceaccounts/mctest-registration", &corev1.ServiceAccount{})
+
+	// kubeconfig stored in the target cluster
+	s := corev1.Secret{}
+	ts.object(t, "/api/v1/namespaces/registered/secrets/source-kubeconfig", &s)
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(s.Data[infra.DefaultRegistrationSecretKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != scfg.Host || cfg.BearerToken != "minted" || cfg.ServerName != "example.com" || !bytes.Equal(cfg.CAData, scfg.CAData) {
+		t.Errorf("unexpected kubeconfig: host %s, token %s, server name %s, CA %s", cfg.Host, cfg.BearerToken, cfg.ServerName, cfg.CAData)
+	}
+
+	// the Secret of another registration is not overwritten
+	if _, err := infra.RegisterCluster(ctx, source, target, opts); !kerrors.IsAlreadyExists(err) {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+	ts.object(t, "/api/v1/namespaces/registered/secrets/source-kubeconfig", &corev1.Secret{})
+
+	if err := r.Unregister(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pp := ss.paths(); len(pp) != 0 {
+		t.Errorf("expected source cluster's resources to be deleted, found %v", pp)
+	}
+	if pp := ts.paths(); len(pp) != 0 {
+		t.Errorf("expected target cluster's Secret to be deleted, found %v", pp)
+	}
+}
+
+func Test_RegisterCluster_NamespacedSource(t *testing.T) {
+	// the ServiceAccount exists already and must survive Unregister
+	sa := "/api/v1/namespaces/spoke-1/serviceaccounts/mctest-registration"
+	ss, scfg := newFakeRegistrationServer(t, sa)
+	scfg.Insecure, scfg.CAData = true, nil
+	source, err := kube.NewNamespaced(scfg, client.Options{Scheme: scheme.Scheme}, "spoke-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, tcfg := newFakeRegistrationServer(t)
+	target, err := kube.New(tcfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	r, err := infra.RegisterCluster(ctx, source, target, infra.DefaultRegistrationOptions("spoke-1-kubeconfig", "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no cluster-scoped request
+	rr := ss.recorded()
+	for _, q := range rr {
+		if strings.Contains(q, "clusterrolebindings") {
+			t.Errorf("unexpected cluster-scoped request %s", q)
+		}
+	}
+
+	// ServiceAccount bound in the client's namespace
+	rb := rbacv1.RoleBinding{}
+	ss.object(t, "/apis/rbac.authorization.k8s.io/v1/namespaces/spoke-1/rolebindings/mctest-registration-cluster-admin", &rb)
+	if len(rb.Subjects) != 1 || rb.Subjects[0].Namespace != "spoke-1" || rb.RoleRef.Name != "cluster-admin" {
+		t.Errorf("expected RoleBinding of cluster-admin to spoke-1/mctest-registration, got %v %v", rb.Subjects, rb.RoleRef)
+	}
+	if !slices.Contains(rr, "POST "+sa+"/token") {
+		t.Errorf("expected token to be minted for %s, got requests %v", sa, rr)
+	}
+
+	s := corev1.Secret{}
+	ts.object(t, "/api/v1/namespaces/default/secrets/spoke-1-kubeconfig", &s)
+	cfg, err := clientcmd.RESTConfigFromKubeConfig(s.Data[infra.DefaultRegistrationSecretKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Insecure {
+		t.Error("expected kubeconfig to skip TLS verification as the source client")
+	}
+
+	if err := r.Unregister(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pp := ss.paths(); len(pp) != 1 || pp[0] != sa {
+		t.Errorf("expected only the pre-existing ServiceAccount to be left, found %v", pp)
+	}
+}
@@ -0,0 +1,172 @@
+package static
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+var ErrNoClusters error = fmt.Errorf("error no clusters configured")
+
+var _ infra.ClusterProvisioner = &StaticProvisioner{}
+var _ infra.ClusterNamesProvider = &StaticProvisioner{}
+
+// ResetFunc brings a pre-existing cluster back to a known state
+type ResetFunc func(ctx context.Context, name string, cfg *rest.Config) error
+
+// StaticProvisioner provides access to pre-existing clusters.
+// Provision and Unprovision do not create nor delete clusters,
+// they just run the optional Reset function on each of them.
+type StaticProvisioner struct {
+	// Optional function invoked on every cluster by Provision and Unprovision
+	Reset ResetFunc
+
+	clusters map[string]rest.Config
+}
+
+// NewFromDirectory builds a StaticProvisioner using the current context
+// of each kubeconfig file in the given directory.
+// Clusters are named after the files, without extension.
+func NewFromDirectory(dir string, reset ResetFunc) (infra.ClusterProvisioner, error) {
+	ee, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cc := map[string]rest.Config{}
+	for _, e := range ee {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		kd, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		cfg, err := clientcmd.RESTConfigFromKubeConfig(kd)
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubeconfig %s: %w", e.Name(), err)
+		}
+
+		n := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		cc[n] = *cfg
+	}
+
+	return newStaticProvisioner(cc, reset)
+}
+
+// NewFromKubeconfig builds a StaticProvisioner with a cluster for each context
+// of the given kubeconfig file. Clusters are named after the contexts.
+func NewFromKubeconfig(path string, reset ResetFunc) (infra.ClusterProvisioner, error) {
+	kc, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cc := map[string]rest.Config{}
+	for n := range kc.Contexts {
+		cfg, err := clientcmd.NewNonInteractiveClientConfig(*kc, n, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error loading context %s from kubeconfig %s: %w", n, path, err)
+		}
+		cc[n] = *cfg
+	}
+
+	return newStaticProvisioner(cc, reset)
+}
+
+func newStaticProvisioner(clusters map[string]rest.Config, reset ResetFunc) (*StaticProvisioner, error) {
+	if len(clusters) == 0 {
+		return nil, ErrNoClusters
+	}
+
+	return &StaticProvisioner{
+		Reset:    reset,
+		clusters: clusters,
+	}, nil
+}
+
+// returns the kubeconfigs of the configured clusters
+func (p *StaticProvisioner) GetAllAdminKubeconfigs(ctx context.Context) (map[string]rest.Config, error) {
+	cfgs := make(map[string]rest.Config, len(p.clusters))
+	for n, c := range p.clusters {
+		cfgs[n] = *rest.CopyConfig(&c)
+	}
+	return cfgs, nil
+}
+
+// Provision runs the Reset function, if any, on the configured clusters
+func (p *StaticProvisioner) Provision(ctx context.Context) error {
+	return p.reset(ctx)
+}
+
+// Returns the number of configured clusters
+func (p *StaticProvisioner) NumClustersProvisionedInProvisionRound() int {
+	return len(p.clusters)
+}
+
+// Returns the names of the configured clusters
+func (p *StaticProvisioner) ClusterNames() []string {
+	nn := make([]string, 0, len(p.clusters))
+	for n := range p.clusters {
+		nn = append(nn, n)
+	}
+	sort.Strings(nn)
+	return nn
+}
+
+// Unprovision runs the Reset function, if any, on the configured clusters
+func (p *StaticProvisioner) Unprovision(ctx context.Context) error {
+	return p.reset(ctx)
+}
+
+// Wait for the configured clusters to be reachable and healthy
+func (p *StaticProvisioner) WaitForProvisionedClusters(ctx context.Context) error {
+	return poll.Do(ctx, 5*time.Second, func(ctx context.Context) error {
+		for n, c := range p.clusters {
+			k, err := kube.New(rest.CopyConfig(&c), client.Options{Scheme: scheme.Scheme})
+			if err != nil {
+				return err
+			}
+
+			if _, err := k.Livez(ctx); err != nil {
+				log.Printf("error checking livez of cluster %s: %v", n, err)
+				return err
+			}
+
+			if _, err := k.Healthz(ctx); err != nil {
+				log.Printf("error checking healthz of cluster %s: %v", n, err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *StaticProvisioner) reset(ctx context.Context) error {
+	if p.Reset == nil {
+		return nil
+	}
+
+	for n, c := range p.clusters {
+		if err := p.Reset(ctx, n, rest.CopyConfig(&c)); err != nil {
+			return fmt.Errorf("error resetting cluster %s: %w", n, err)
+		}
+	}
+	return nil
+}
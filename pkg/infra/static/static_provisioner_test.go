@@ -0,0 +1,110 @@
+package static_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/filariow/mctest/pkg/infra/static"
+)
+
+const kubeconfigTemplate string = `apiVersion: v1
+kind: Config
+clusters:
+- name: one
+  cluster:
+    server: https://one.example.com:6443
+- name: two
+  cluster:
+    server: https://two.example.com:6443
+users:
+- name: admin
+  user:
+    token: dummy
+contexts:
+- name: one
+  context:
+    cluster: one
+    user: admin
+- name: two
+  context:
+    cluster: two
+    user: admin
+current-context: %s
+`
+
+func writeKubeconfig(t *testing.T, path, currentContext string) {
+	t.Helper()
+
+	d := fmt.Sprintf(kubeconfigTemplate, currentContext)
+	if err := os.WriteFile(path, []byte(d), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_NewFromDirectory(t *testing.T) {
+	d := t.TempDir()
+	writeKubeconfig(t, filepath.Join(d, "hub.yaml"), "one")
+	writeKubeconfig(t, filepath.Join(d, "spoke-1.yaml"), "two")
+	writeKubeconfig(t, filepath.Join(d, ".hidden"), "two")
+
+	p, err := static.NewFromDirectory(d, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgs, err := p.GetAllAdminKubeconfigs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"hub":     "https://one.example.com:6443",
+		"spoke-1": "https://two.example.com:6443",
+	}
+	if len(cfgs) != len(expected) {
+		t.Fatalf("expected %d clusters, got %d: %v", len(expected), len(cfgs), cfgs)
+	}
+	for n, h := range expected {
+		if c, ok := cfgs[n]; !ok || c.Host != h {
+			t.Errorf("expected cluster %s with host %s, got %v", n, h, c.Host)
+		}
+	}
+}
+
+func Test_NewFromKubeconfig(t *testing.T) {
+	kp := filepath.Join(t.TempDir(), "kubeconfig")
+	writeKubeconfig(t, kp, "one")
+
+	resets := []string{}
+	p, err := static.NewFromKubeconfig(kp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.(*static.StaticProvisioner).Reset = func(ctx context.Context, name string, _ *rest.Config) error {
+		resets = append(resets, name)
+		return nil
+	}
+
+	if n := p.NumClustersProvisionedInProvisionRound(); n != 2 {
+		t.Errorf("expected 2 clusters, got %d", n)
+	}
+
+	if err := p.Provision(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(resets) != 2 {
+		t.Errorf("expected Provision to reset 2 clusters, got %v", resets)
+	}
+}
+
+func Test_NewFromDirectoryEmpty(t *testing.T) {
+	if _, err := static.NewFromDirectory(t.TempDir(), nil); !errors.Is(err, static.ErrNoClusters) {
+		t.Errorf("expected ErrNoClusters, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrApplyConflict error = fmt.Errorf("error field manager conflict")
+
+// ApplyResult is the outcome of server-side applying an object
+type ApplyResult struct {
+	// The object returned by the server, or the requested one if apply failed
+	Object unstructured.Unstructured
+	// Error returned by the server, if any
+	Err error
+	// Fields owned by other field managers that prevented the apply, if any
+	Conflicts []metav1.StatusCause
+}
+
+// Apply server-side applies the given objects with the given field manager.
+// If force is true, conflicting fields owned by other managers are taken over.
+// A result is returned for each object, the error joins the failed ones.
+func (k *Kubernetes) Apply(ctx context.Context, objs []unstructured.Unstructured, fieldManager string, force bool) ([]ApplyResult, error) {
+	oo := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if force {
+		oo = append(oo, client.ForceOwnership)
+	}
+
+	rr := make([]ApplyResult, len(objs))
+	errs := []error{}
+	for i, o := range objs {
+		u := o.DeepCopy()
+		u.SetManagedFields(nil)
+
+		err := k.Patch(ctx, u, client.Apply, oo...)
+		rr[i] = ApplyResult{Object: *u, Err: err, Conflicts: applyConflicts(err)}
+
+		if err == nil {
+			continue
+		}
+
+		if len(rr[i].Conflicts) > 0 {
+			errs = append(errs, fmt.Errorf("%w: applying %s %s/%s: %w", ErrApplyConflict, o.GetKind(), o.GetNamespace(), o.GetName(), err))
+			continue
+		}
+		errs = append(errs, fmt.Errorf("error applying %s %s/%s: %w", o.GetKind(), o.GetNamespace(), o.GetName(), err))
+	}
+	return rr, errors.Join(errs...)
+}
+
+// Apply server-side applies the given objects in the client's namespace
+func (k *NamespacedKubernetes) Apply(ctx context.Context, objs []unstructured.Unstructured, fieldManager string, force bool) ([]ApplyResult, error) {
+	oo := make([]unstructured.Unstructured, len(objs))
+	for i, o := range objs {
+		lo := o.DeepCopy()
+		lo.SetNamespace(k.Namespace)
+		oo[i] = *lo
+	}
+	return k.Kubernetes.Apply(ctx, oo, fieldManager, force)
+}
+
+func applyConflicts(err error) []metav1.StatusCause {
+	if !kerrors.IsConflict(err) {
+		return nil
+	}
+
+	var s kerrors.APIStatus
+	if !errors.As(err, &s) || s.Status().Details == nil {
+		return nil
+	}
+
+	cc := []metav1.StatusCause{}
+	for _, c := range s.Status().Details.Causes {
+		if c.Type == metav1.CauseTypeFieldManagerConflict {
+			cc = append(cc, c)
+		}
+	}
+	return cc
+}
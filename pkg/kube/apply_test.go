@@ -0,0 +1,194 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeApplyServer serves server-side apply of ConfigMaps.
+// Applying the "conflicting" ConfigMap without force fails with a field manager conflict,
+// applying the "invalid" one fails validation.
+type fakeApplyServer struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (s *fakeApplyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case "/apis":
+		_ = e.Encode(metav1.APIGroupList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"}})
+		return
+	case "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: []string{"patch"}},
+			},
+		})
+		return
+	}
+
+	s.mu.Lock()
+	s.queries = append(s.queries, r.URL.RawQuery)
+	s.mu.Unlock()
+
+	status := func(code int32, reason metav1.StatusReason, causes ...metav1.StatusCause) {
+		w.WriteHeader(int(code))
+		_ = e.Encode(metav1.Status{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+			Status:   metav1.StatusFailure,
+			Reason:   reason,
+			Code:     code,
+			Details:  &metav1.StatusDetails{Name: "cm", Kind: "configmaps", Causes: causes},
+		})
+	}
+
+	force := r.URL.Query().Get("force") == "true"
+	switch {
+	case r.Method != http.MethodPatch || r.Header.Get("Content-Type") != string(client.Apply.Type()):
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case r.URL.Path == "/api/v1/namespaces/test/configmaps/conflicting" && !force:
+		status(http.StatusConflict, metav1.StatusReasonConflict,
+			metav1.StatusCause{Type: metav1.CauseTypeFieldManagerConflict, Message: `conflict with "kubectl"`, Field: ".data.key"},
+			metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid, Message: "unrelated cause"},
+		)
+	case r.URL.Path == "/api/v1/namespaces/test/configmaps/invalid":
+		status(http.StatusUnprocessableEntity, metav1.StatusReasonInvalid,
+			metav1.StatusCause{Type: metav1.CauseTypeFieldValueInvalid, Message: "invalid value", Field: ".data"},
+		)
+	default:
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}
+}
+
+func appliedConfigMap(name string) unstructured.Unstructured {
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("test")
+	u.SetName(name)
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl"}})
+	return u
+}
+
+func Test_Apply(t *testing.T) {
+	tt := []struct {
+		name  string
+		force bool
+		// expected conflicting fields of the second object
+		conflicts   []string
+		conflict    bool
+		failed      bool
+		expectQuery string
+	}{
+		{
+			name:        "no conflict",
+			expectQuery: "fieldManager=mctest",
+		},
+		{
+			name:        "conflict",
+			conflicts:   []string{".data.key"},
+			conflict:    true,
+			failed:      true,
+			expectQuery: "fieldManager=mctest",
+		},
+		{
+			name:        "forced conflict",
+			force:       true,
+			expectQuery: "fieldManager=mctest&force=true",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &fakeApplyServer{}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+			k, err := kube.New(cfg, client.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			objs := []unstructured.Unstructured{appliedConfigMap("cm")}
+			if tc.failed || tc.force {
+				objs = append(objs, appliedConfigMap("conflicting"))
+			}
+
+			rr, err := k.Apply(context.Background(), objs, "mctest", tc.force)
+			if tc.failed != (err != nil) {
+				t.Fatalf("expected failure %v, got %v", tc.failed, err)
+			}
+			if tc.conflict != errors.Is(err, kube.ErrApplyConflict) {
+				t.Errorf("expected ErrApplyConflict %v, got %v", tc.conflict, err)
+			}
+			if len(rr) != len(objs) {
+				t.Fatalf("expected %d results, got %d", len(objs), len(rr))
+			}
+
+			if rr[0].Err != nil || len(rr[0].Conflicts) != 0 {
+				t.Errorf("expected first object to be applied, got %v %v", rr[0].Err, rr[0].Conflicts)
+			}
+			if mf := rr[0].Object.GetManagedFields(); len(mf) != 0 {
+				t.Errorf("expected managed fields to be cleared before applying, got %v", mf)
+			}
+			if len(rr) > 1 {
+				cc := []string{}
+				for _, c := range rr[1].Conflicts {
+					cc = append(cc, c.Field)
+				}
+				if len(cc) != len(tc.conflicts) || (len(cc) > 0 && cc[0] != tc.conflicts[0]) {
+					t.Errorf("expected conflicts %v, got %v", tc.conflicts, cc)
+				}
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			for _, q := range s.queries {
+				if q != tc.expectQuery {
+					t.Errorf("expected query %q, got %q", tc.expectQuery, q)
+				}
+			}
+		})
+	}
+}
+
+func Test_Apply_Invalid(t *testing.T) {
+	srv := httptest.NewServer(&fakeApplyServer{})
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	k, err := kube.New(cfg, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr, err := k.Apply(context.Background(), []unstructured.Unstructured{appliedConfigMap("invalid")}, "mctest", false)
+	if err == nil || errors.Is(err, kube.ErrApplyConflict) {
+		t.Errorf("expected a non conflict error, got %v", err)
+	}
+	if len(rr) != 1 || rr[0].Err == nil || len(rr[0].Conflicts) != 0 {
+		t.Errorf("expected failed result with no conflicts, got %v", rr)
+	}
+}
@@ -0,0 +1,142 @@
+package kube
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// BundlePhase is the phase a bundle's object is applied in.
+// Phases are applied in ascending order.
+type BundlePhase int
+
+const (
+	PhaseNamespaces BundlePhase = iota
+	PhaseCustomResourceDefinitions
+	PhaseRBAC
+	PhaseWorkloads
+	PhaseCustomResources
+)
+
+const crdEstablishedTimeout = 1 * time.Minute
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+var rbacKinds = map[schema.GroupKind]struct{}{
+	{Group: "", Kind: "ServiceAccount"}:                              {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:        {},
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:               {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}: {},
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:        {},
+}
+
+// SortBundle groups the objects of a bundle by the phase they have to be applied in:
+// Namespaces, CustomResourceDefinitions, RBAC, workloads and finally Custom Resources.
+// Objects of a kind defined by one of the bundle's CRDs or belonging to a
+// non built-in API group are considered Custom Resources.
+// The relative order of objects in the same phase is preserved.
+func SortBundle(objs []unstructured.Unstructured) [][]unstructured.Unstructured {
+	crds := bundleCustomKinds(objs)
+
+	pp := map[BundlePhase][]unstructured.Unstructured{}
+	for _, o := range objs {
+		p := bundlePhase(o, crds)
+		pp[p] = append(pp[p], o)
+	}
+
+	kk := make([]BundlePhase, 0, len(pp))
+	for k := range pp {
+		kk = append(kk, k)
+	}
+	sort.Slice(kk, func(i, j int) bool { return kk[i] < kk[j] })
+
+	ss := make([][]unstructured.Unstructured, 0, len(kk))
+	for _, k := range kk {
+		ss = append(ss, pp[k])
+	}
+	return ss
+}
+
+// ApplyBundle server-side applies the bundle's objects phase by phase, see SortBundle.
+// After the CRDs are applied it waits for them to be Established.
+// Discovery information is reset between phases, so that new kinds can be mapped.
+func (k *Kubernetes) ApplyBundle(ctx context.Context, objs []unstructured.Unstructured, fieldManager string) error {
+	return applyBundle(ctx, k, objs, fieldManager)
+}
+
+// ApplyBundle server-side applies the bundle's objects phase by phase in the client's namespace
+func (k *NamespacedKubernetes) ApplyBundle(ctx context.Context, objs []unstructured.Unstructured, fieldManager string) error {
+	return applyBundle(ctx, k, objs, fieldManager)
+}
+
+func applyBundle(ctx context.Context, k Client, objs []unstructured.Unstructured, fieldManager string) error {
+	for _, pp := range SortBundle(objs) {
+		if _, err := k.Apply(ctx, pp, fieldManager, true); err != nil {
+			return err
+		}
+
+		if pp[0].GroupVersionKind().GroupKind() == crdGroupKind {
+			for _, crd := range pp {
+				if err := waitForCRDEstablished(ctx, k, crd); err != nil {
+					return err
+				}
+			}
+		}
+
+		// the phase may have introduced new kinds
+		k.Refresh()
+	}
+	return nil
+}
+
+func waitForCRDEstablished(ctx context.Context, k Client, crd unstructured.Unstructured) error {
+	ctx, cancel := context.WithTimeout(ctx, crdEstablishedTimeout)
+	defer cancel()
+
+	_, err := k.WaitFor(ctx, crd, HasCondition("Established", metav1.ConditionTrue))
+	return err
+}
+
+func bundlePhase(o unstructured.Unstructured, crds map[schema.GroupKind]struct{}) BundlePhase {
+	gk := o.GroupVersionKind().GroupKind()
+	switch {
+	case gk == schema.GroupKind{Kind: "Namespace"}:
+		return PhaseNamespaces
+	case gk == crdGroupKind:
+		return PhaseCustomResourceDefinitions
+	}
+
+	if _, ok := rbacKinds[gk]; ok {
+		return PhaseRBAC
+	}
+	if _, ok := crds[gk]; ok || !isBuiltInGroup(gk.Group) {
+		return PhaseCustomResources
+	}
+	return PhaseWorkloads
+}
+
+// bundleCustomKinds returns the kinds defined by the bundle's CRDs
+func bundleCustomKinds(objs []unstructured.Unstructured) map[schema.GroupKind]struct{} {
+	kk := map[schema.GroupKind]struct{}{}
+	for _, o := range objs {
+		if o.GroupVersionKind().GroupKind() != crdGroupKind {
+			continue
+		}
+
+		g, _, _ := unstructured.NestedString(o.Object, "spec", "group")
+		n, _, _ := unstructured.NestedString(o.Object, "spec", "names", "kind")
+		kk[schema.GroupKind{Group: g, Kind: n}] = struct{}{}
+	}
+	return kk
+}
+
+// isBuiltInGroup checks if the group is served by kubernetes itself,
+// e.g. the core group, apps or networking.k8s.io
+func isBuiltInGroup(group string) bool {
+	return group == "" || !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
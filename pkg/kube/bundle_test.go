@@ -0,0 +1,77 @@
+package kube_test
+
+import (
+	"testing"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_SortBundle(t *testing.T) {
+	spec := `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller-manager
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: manager
+---
+apiVersion: networking.k8s.io/v1
+kind: Gadget
+metadata:
+  name: my-gadget
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gadgets.networking.k8s.io
+spec:
+  group: networking.k8s.io
+  names:
+    kind: Gadget
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: manager
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: system
+`
+	uu, err := kube.ParseManifests(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]string{
+		{"Namespace"},
+		{"CustomResourceDefinition"},
+		{"ClusterRoleBinding", "ServiceAccount"},
+		{"Deployment"},
+		{"Widget", "Gadget"},
+	}
+
+	pp := kube.SortBundle(uu)
+	if len(pp) != len(expected) {
+		t.Fatalf("expected %d phases, got %d", len(expected), len(pp))
+	}
+	for i, p := range pp {
+		if len(p) != len(expected[i]) {
+			t.Fatalf("phase %d: expected %d objects, got %d", i, len(expected[i]), len(p))
+		}
+		for j, o := range p {
+			if o.GetKind() != expected[i][j] {
+				t.Errorf("phase %d: expected object %d to be a %s, got %s", i, j, expected[i][j], o.GetKind())
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+)
+
+var ErrInteractionNotFound error = fmt.Errorf("error no recorded interaction matches the request")
+
+// CassetteMode defines whether a cassette records or replays interactions
+type CassetteMode string
+
+const (
+	// Requests are sent to the API server and their interactions recorded
+	CassetteRecord CassetteMode = "record"
+	// Requests are served with the recorded interactions, no API server is contacted
+	CassetteReplay CassetteMode = "replay"
+)
+
+// query parameters that change at every run and are ignored when matching requests
+var volatileQueryParameters = []string{"timeoutSeconds"}
+
+// Interaction is a recorded HTTP request and its response.
+// For watches the response body is the stream received until the watch was stopped.
+// Bodies that are not valid UTF-8, e.g. protobuf ones, are base64 encoded.
+// Secrets' data and minted tokens are redacted.
+type Interaction struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	RequestBody  string      `json:"requestBody,omitempty"`
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"responseBody,omitempty"`
+	Base64       bool        `json:"base64,omitempty"`
+
+	info         RequestInfo
+	requestBody  []byte
+	responseBody []byte
+}
+
+// encode sets the serialized bodies, redacting sensitive data
+func (i *Interaction) encode() {
+	rq, rs := redactInteraction(i.info, i.requestBody), redactInteraction(i.info, i.responseBody)
+	i.Base64 = !utf8.Valid(rq) || !utf8.Valid(rs)
+	if i.Base64 {
+		i.RequestBody = base64.StdEncoding.EncodeToString(rq)
+		i.ResponseBody = base64.StdEncoding.EncodeToString(rs)
+		return
+	}
+	i.RequestBody, i.ResponseBody = string(rq), string(rs)
+}
+
+// decode sets the bodies from the serialized ones
+func (i *Interaction) decode() error {
+	if !i.Base64 {
+		i.requestBody, i.responseBody = []byte(i.RequestBody), []byte(i.ResponseBody)
+		return nil
+	}
+
+	var err error
+	if i.requestBody, err = base64.StdEncoding.DecodeString(i.RequestBody); err != nil {
+		return err
+	}
+	i.responseBody, err = base64.StdEncoding.DecodeString(i.ResponseBody)
+	return err
+}
+
+// redactInteraction redacts the body of a request or response, see redact.
+// Watch streams are redacted event by event. Sensitive bodies that are not JSON are dropped.
+func redactInteraction(info RequestInfo, body []byte) []byte {
+	if len(body) == 0 || !isSensitive(info) {
+		return body
+	}
+	if info.Verb != "watch" {
+		return redact(info, body)
+	}
+
+	rb := []byte{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	for {
+		e := struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}{}
+		// events truncated when the watch was stopped are dropped
+		if err := d.Decode(&e); err != nil {
+			return rb
+		}
+
+		e.Object = redact(info, e.Object)
+		b, err := json.Marshal(e)
+		if err != nil {
+			return rb
+		}
+		rb = append(append(rb, b...), '\n')
+	}
+}
+
+// Cassette records the HTTP interactions of the clients it wraps into a file,
+// or serves them back to allow clients to run without an API server.
+//
+// On replay, requests are matched by method and URL. Interactions matching the same
+// request are served in the order they were recorded, the last one being repeated once all were served.
+// Watches are not repeated: once all were served, watch streams stay idle until the request is done.
+type Cassette struct {
+	Path string
+	Mode CassetteMode
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	// replay's position by request
+	served map[string]int
+}
+
+// NewCassette returns a cassette for the file at path.
+// In replay mode the interactions are loaded from the file.
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{Path: path, Mode: mode, served: map[string]int{}}
+	switch mode {
+	case CassetteRecord:
+		return c, nil
+	case CassetteReplay:
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(d, &c.interactions); err != nil {
+			return nil, fmt.Errorf("error reading cassette %s: %w", path, err)
+		}
+		for _, i := range c.interactions {
+			if err := i.decode(); err != nil {
+				return nil, fmt.Errorf("error reading cassette %s: %w", path, err)
+			}
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("error invalid cassette mode %q", mode)
+	}
+}
+
+// WrapConfig returns a copy of cfg whose requests are recorded or replayed.
+// On replay, the transport and TLS configuration of cfg are replaced.
+func (c *Cassette) WrapConfig(cfg *rest.Config) *rest.Config {
+	rc := rest.CopyConfig(cfg)
+	if c.Mode == CassetteReplay {
+		rc.TLSClientConfig = rest.TLSClientConfig{}
+		rc.WrapTransport = nil
+		rc.Transport = &replayRoundTripper{cassette: c}
+		return rc
+	}
+
+	rc.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &cassetteRoundTripper{cassette: c, next: rt}
+	})
+	return rc
+}
+
+// WrapClient rebuilds the client k on top of a copy of its rest.Config whose requests
+// are recorded or replayed, preserving its namespace scope
+func (c *Cassette) WrapClient(k Client) (Client, error) {
+	return WithRESTConfig(k, c.WrapConfig(k.RESTConfig()))
+}
+
+// Save writes the recorded interactions to the cassette's file.
+// Watches still running are saved with the events received so far.
+func (c *Cassette) Save() error {
+	if c.Mode != CassetteRecord {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, i := range c.interactions {
+		i.encode()
+	}
+	d, err := yaml.Marshal(c.interactions)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.Path, d, 0o644)
+}
+
+// add reserves the next position for the interaction, so that
+// interactions are saved in the order requests were sent
+func (c *Cassette) add(i *Interaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, i)
+}
+
+// next returns the interaction to serve for the request and whether
+// all the interactions matching the request had already been served
+func (c *Cassette) next(req *http.Request) (*Interaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	k := interactionKey(req.Method, req.URL)
+	ii := []*Interaction{}
+	for _, i := range c.interactions {
+		u, err := url.Parse(i.URL)
+		if err != nil {
+			return nil, false, err
+		}
+		if interactionKey(i.Method, u) == k {
+			ii = append(ii, i)
+		}
+	}
+	if len(ii) == 0 {
+		return nil, false, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL.RequestURI())
+	}
+
+	n := c.served[k]
+	c.served[k] = n + 1
+	return ii[min(n, len(ii)-1)], n >= len(ii), nil
+}
+
+// interactionKey identifies a request by method, path and query parameters, volatile ones excluded
+func interactionKey(method string, u *url.URL) string {
+	q := u.Query()
+	for _, p := range volatileQueryParameters {
+		q.Del(p)
+	}
+	return method + " " + u.Path + "?" + q.Encode()
+}
+
+type cassetteRoundTripper struct {
+	cassette *Cassette
+	next     http.RoundTripper
+}
+
+func (t *cassetteRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := &Interaction{Method: req.Method, URL: req.URL.RequestURI(), info: ParseRequestInfo(req)}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		i.requestBody = b
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	i.StatusCode, i.Header = resp.StatusCode, resp.Header.Clone()
+	t.cassette.add(i)
+
+	// the body is recorded while it is read, so that watch streams are recorded too
+	resp.Body = &recordingBody{ReadCloser: resp.Body, cassette: t.cassette, interaction: i}
+	return resp, nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+
+	cassette    *Cassette
+	interaction *Interaction
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.cassette.mu.Lock()
+		b.interaction.responseBody = append(b.interaction.responseBody, p[:n]...)
+		b.cassette.mu.Unlock()
+	}
+	return n, err
+}
+
+type replayRoundTripper struct {
+	cassette *Cassette
+}
+
+func (t *replayRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	i, exhausted, err := t.cassette.next(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader(i.responseBody))
+	cl := int64(len(i.responseBody))
+	if exhausted && ParseRequestInfo(req).Verb == "watch" {
+		body, cl = newIdleBody(req.Context()), -1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.StatusCode, http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Header.Clone(),
+		Body:          body,
+		ContentLength: cl,
+		Request:       req,
+	}, nil
+}
+
+// idleBody is a stream with no data, ending when its request is done or it is closed
+type idleBody struct {
+	ctx    context.Context
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newIdleBody(ctx context.Context) *idleBody {
+	return &idleBody{ctx: ctx, closed: make(chan struct{})}
+}
+
+func (b *idleBody) Read([]byte) (int, error) {
+	select {
+	case <-b.ctx.Done():
+		return 0, io.EOF
+	case <-b.closed:
+		return 0, io.EOF
+	}
+}
+
+func (b *idleBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
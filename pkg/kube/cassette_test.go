@@ -0,0 +1,196 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_Cassette(t *testing.T) {
+	cp := path.Join(t.TempDir(), "cassette.yaml")
+
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("test")
+	u.SetName("watched")
+
+	waitForReady := func(t *testing.T, cfg *rest.Config) error {
+		t.Helper()
+
+		k, err := kube.New(cfg, client.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = k.WaitFor(ctx, u, kube.FieldEquals("{.data.phase}", "Ready"))
+		return err
+	}
+
+	t.Run("interactions are recorded", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeWatchServer{})
+		defer srv.Close()
+
+		c, err := kube.NewCassette(cp, kube.CassetteRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := waitForReady(t, c.WrapConfig(&rest.Config{Host: srv.URL})); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("interactions are replayed with no API server", func(t *testing.T) {
+		c, err := kube.NewCassette(cp, kube.CassetteReplay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := waitForReady(t, c.WrapConfig(&rest.Config{Host: "https://127.0.0.1:1"})); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("requests not recorded fail", func(t *testing.T) {
+		c, err := kube.NewCassette(cp, kube.CassetteReplay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := kube.New(c.WrapConfig(&rest.Config{Host: "https://127.0.0.1:1"}), client.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lu := u.DeepCopy()
+		err = k.Get(context.Background(), client.ObjectKey{Namespace: "other", Name: "watched"}, lu)
+		if !errors.Is(err, kube.ErrInteractionNotFound) {
+			t.Errorf("expected ErrInteractionNotFound, got %v", err)
+		}
+	})
+}
+
+// secretWatchServer streams a single Secret event to watches, and echoes back other requests
+type secretWatchServer struct{}
+
+func (secretWatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") != "true" {
+		echoServer{}.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(metav1.WatchEvent{
+		Type: string(watch.Added),
+		Object: runtime.RawExtension{Object: &corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "test"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		}},
+	})
+}
+
+func Test_Cassette_Secrets(t *testing.T) {
+	cp := path.Join(t.TempDir(), "cassette.yaml")
+
+	watchSecrets := func(t *testing.T, cli *kubernetes.Clientset, timeout time.Duration) []watch.Event {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		w, err := cli.CoreV1().Secrets("test").Watch(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Stop()
+
+		ee := []watch.Event{}
+		for e := range w.ResultChan() {
+			ee = append(ee, e)
+		}
+		return ee
+	}
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		srv := httptest.NewServer(secretWatchServer{})
+		defer srv.Close()
+
+		c, err := kube.NewCassette(cp, kube.CassetteRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cli, err := kubernetes.NewForConfig(c.WrapConfig(&rest.Config{Host: srv.URL}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "s"},
+			StringData: map[string]string{"password": "secret"},
+		}
+		if _, err := cli.CoreV1().Secrets("test").Create(context.Background(), s, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if ee := watchSecrets(t, cli, 10*time.Second); len(ee) != 1 {
+			t.Fatalf("expected 1 event to be recorded, got %v", ee)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := os.ReadFile(cp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(d), `"secret"`) || strings.Contains(string(d), "c2VjcmV0") {
+			t.Errorf("expected Secrets' data to be redacted, got:\n%s", d)
+		}
+		if n := strings.Count(string(d), "REDACTED"); n != 3 {
+			t.Errorf("expected request, response and watch event to be redacted, found %d redacted fields:\n%s", n, d)
+		}
+	})
+
+	t.Run("exhausted watches are idle", func(t *testing.T) {
+		c, err := kube.NewCassette(cp, kube.CassetteReplay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cli, err := kubernetes.NewForConfig(c.WrapConfig(&rest.Config{Host: "https://127.0.0.1:1"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ee := watchSecrets(t, cli, 10*time.Second); len(ee) != 1 {
+			t.Fatalf("expected the recorded event to be replayed, got %v", ee)
+		}
+
+		s := time.Now()
+		if ee := watchSecrets(t, cli, 200*time.Millisecond); len(ee) != 0 {
+			t.Errorf("expected no event once the recorded watch was served, got %v", ee)
+		}
+		if d := time.Since(s); d < 200*time.Millisecond {
+			t.Errorf("expected the exhausted watch to last until the request is done, ended after %v", d)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultTokenExpiration time.Duration = 1 * time.Hour
+	// tokens are refreshed when less than this fraction of their lifetime is left
+	tokenRefreshThreshold float64 = 0.2
+)
+
+var _ CredentialSource = &ServiceAccountTokenSource{}
+var _ CredentialSource = &ImpersonationSource{}
+
+// CredentialSource provides the rest.Config to build clients with
+type CredentialSource interface {
+	// Returns a rest.Config authenticating with the source's credentials
+	RESTConfig() (*rest.Config, error)
+}
+
+// ServiceAccountTokenSource authenticates as a ServiceAccount with tokens
+// minted through the TokenRequest API. Tokens are refreshed before they expire.
+type ServiceAccountTokenSource struct {
+	// Namespace and name of the ServiceAccount
+	Namespace string
+	Name      string
+	// Requested expiration of minted tokens
+	Expiration time.Duration
+
+	cfg *rest.Config
+	cli kubernetes.Interface
+
+	mu     sync.Mutex
+	token  string
+	issued time.Time
+	expiry time.Time
+}
+
+// NewServiceAccountTokenSource returns a source minting tokens for the given ServiceAccount.
+// cfg is used to mint the tokens and as base for the returned rest.Configs: its credentials
+// are replaced by the ServiceAccount's ones.
+func NewServiceAccountTokenSource(cfg *rest.Config, namespace, name string, expiration time.Duration) (*ServiceAccountTokenSource, error) {
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServiceAccountTokenSource{
+		Namespace:  namespace,
+		Name:       name,
+		Expiration: expiration,
+
+		cfg: rest.CopyConfig(cfg),
+		cli: cli,
+	}, nil
+}
+
+// Token returns a valid token for the ServiceAccount, minting a new one
+// if the current one is close to expiration
+func (s *ServiceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.token != "" && now.Before(s.refreshTime()) {
+		return s.token, nil
+	}
+
+	es := int64(s.Expiration.Seconds())
+	r := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &es}}
+	r, err := s.cli.CoreV1().ServiceAccounts(s.Namespace).CreateToken(ctx, s.Name, r, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error creating token for ServiceAccount %s/%s: %w", s.Namespace, s.Name, err)
+	}
+
+	s.token, s.issued, s.expiry = r.Status.Token, now, r.Status.ExpirationTimestamp.Time
+	return s.token, nil
+}
+
+func (s *ServiceAccountTokenSource) refreshTime() time.Time {
+	l := s.expiry.Sub(s.issued)
+	return s.expiry.Add(-time.Duration(float64(l) * tokenRefreshThreshold))
+}
+
+// RESTConfig returns a rest.Config that authenticates every request
+// with a valid token of the ServiceAccount
+func (s *ServiceAccountTokenSource) RESTConfig() (*rest.Config, error) {
+	cfg := rest.AnonymousClientConfig(s.cfg)
+	cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &tokenRoundTripper{source: s, next: rt}
+	})
+	return cfg, nil
+}
+
+type tokenRoundTripper struct {
+	source *ServiceAccountTokenSource
+	next   http.RoundTripper
+}
+
+func (t *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tk, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+tk)
+	return t.next.RoundTrip(r)
+}
+
+// ImpersonationSource authenticates with the base rest.Config's credentials
+// impersonating the configured user
+type ImpersonationSource struct {
+	Impersonate rest.ImpersonationConfig
+
+	cfg *rest.Config
+}
+
+// NewImpersonationSource returns a source impersonating the given user
+// with the credentials of cfg
+func NewImpersonationSource(cfg *rest.Config, impersonate rest.ImpersonationConfig) *ImpersonationSource {
+	return &ImpersonationSource{
+		Impersonate: impersonate,
+		cfg:         rest.CopyConfig(cfg),
+	}
+}
+
+// NewServiceAccountImpersonationSource returns a source impersonating the given ServiceAccount
+func NewServiceAccountImpersonationSource(cfg *rest.Config, namespace, name string) *ImpersonationSource {
+	return NewImpersonationSource(cfg, rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+		Groups:   []string{"system:serviceaccounts", fmt.Sprintf("system:serviceaccounts:%s", namespace), "system:authenticated"},
+	})
+}
+
+// RESTConfig returns a copy of the base rest.Config with the impersonation configured
+func (s *ImpersonationSource) RESTConfig() (*rest.Config, error) {
+	cfg := rest.CopyConfig(s.cfg)
+	cfg.Impersonate = s.Impersonate
+	return cfg, nil
+}
+
+// NewFromCredentials builds a Kubernetes client authenticating with the given source
+func NewFromCredentials(src CredentialSource, opts client.Options) (*Kubernetes, error) {
+	cfg, err := src.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, opts)
+}
+
+// NewNamespacedFromCredentials builds a NamespacedKubernetes client authenticating with the given source
+func NewNamespacedFromCredentials(src CredentialSource, opts client.Options, namespace string) (*NamespacedKubernetes, error) {
+	cfg, err := src.RESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	return NewNamespaced(cfg, opts, namespace)
+}
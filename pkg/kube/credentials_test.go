@@ -0,0 +1,137 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeTokenServer mints tokens with the given lifetime and records
+// the Authorization header of ConfigMaps list requests
+type fakeTokenServer struct {
+	lifetime time.Duration
+
+	mu     sync.Mutex
+	minted int
+	auths  []string
+}
+
+func (s *fakeTokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/api/v1/namespaces/test/serviceaccounts/runner/token":
+		s.minted++
+		tr := authenticationv1.TokenRequest{
+			TypeMeta: metav1.TypeMeta{APIVersion: "authentication.k8s.io/v1", Kind: "TokenRequest"},
+			Status: authenticationv1.TokenRequestStatus{
+				Token:               fmt.Sprintf("token-%d", s.minted),
+				ExpirationTimestamp: metav1.NewTime(time.Now().Add(s.lifetime)),
+			},
+		}
+		_ = json.NewEncoder(w).Encode(tr)
+	case "/api/v1/namespaces/test/configmaps":
+		s.auths = append(s.auths, r.Header.Get("Authorization"))
+		_ = json.NewEncoder(w).Encode(corev1.ConfigMapList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMapList"}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func listConfigMaps(t *testing.T, src kube.CredentialSource, times int) {
+	t.Helper()
+
+	cfg, err := src.RESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < times; i++ {
+		if _, err := cli.CoreV1().ConfigMaps("test").List(context.Background(), metav1.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_ServiceAccountTokenSource(t *testing.T) {
+	t.Run("token is reused while valid", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeTokenServer{lifetime: time.Hour}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		src, err := kube.NewServiceAccountTokenSource(&rest.Config{Host: srv.URL, BearerToken: "admin"}, "test", "runner", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listConfigMaps(t, src, 3)
+
+		if s.minted != 1 {
+			t.Errorf("expected 1 token to be minted, got %d", s.minted)
+		}
+		for _, a := range s.auths {
+			if a != "Bearer token-1" {
+				t.Errorf("expected requests to be authenticated with token-1, got %q", a)
+			}
+		}
+	})
+
+	t.Run("token is refreshed before expiration", func(t *testing.T) {
+		t.Parallel()
+
+		s := &fakeTokenServer{lifetime: 0}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		src, err := kube.NewServiceAccountTokenSource(&rest.Config{Host: srv.URL, BearerToken: "admin"}, "test", "runner", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listConfigMaps(t, src, 2)
+
+		if s.minted != 2 {
+			t.Errorf("expected 2 tokens to be minted, got %d", s.minted)
+		}
+		if len(s.auths) != 2 || s.auths[1] != "Bearer token-2" {
+			t.Errorf("expected last request to be authenticated with token-2, got %v", s.auths)
+		}
+	})
+}
+
+func Test_ServiceAccountImpersonationSource(t *testing.T) {
+	src := kube.NewServiceAccountImpersonationSource(&rest.Config{Host: "https://127.0.0.1:6443", BearerToken: "admin"}, "test", "runner")
+
+	cfg, err := src.RESTConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u := cfg.Impersonate.UserName; u != "system:serviceaccount:test:runner" {
+		t.Errorf("expected to impersonate system:serviceaccount:test:runner, got %s", u)
+	}
+	if cfg.BearerToken != "admin" {
+		t.Errorf("expected base credentials to be preserved")
+	}
+}
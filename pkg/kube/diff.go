@@ -0,0 +1,151 @@
+package kube
+
+import (
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// metadata fields that change at every write or are set by the server
+var noisyMetadataFields = []string{
+	"managedFields",
+	"resourceVersion",
+	"uid",
+	"creationTimestamp",
+	"generation",
+	"selfLink",
+}
+
+// timestamps fields, removed at any depth
+var noisyTimestampFields = map[string]struct{}{
+	"lastTransitionTime": {},
+	"lastUpdateTime":     {},
+	"lastProbeTime":      {},
+	"lastHeartbeatTime":  {},
+}
+
+const lastAppliedConfigurationAnnotation string = "kubectl.kubernetes.io/last-applied-configuration"
+
+// DiffOptions tunes the diff rendered by Diff
+type DiffOptions struct {
+	// If true, only the fields of the live object that are present in
+	// the expected one are diffed, e.g. when the expected object is partial
+	OnlyExpectedFields bool
+}
+
+// StripNoisyFields returns a copy of the object with no managedFields,
+// resourceVersion, uid, timestamps and other fields managed by the server
+func StripNoisyFields(u unstructured.Unstructured) *unstructured.Unstructured {
+	s := u.DeepCopy()
+	for _, f := range noisyMetadataFields {
+		unstructured.RemoveNestedField(s.Object, "metadata", f)
+	}
+	unstructured.RemoveNestedField(s.Object, "metadata", "annotations", lastAppliedConfigurationAnnotation)
+	if aa := s.GetAnnotations(); aa != nil && len(aa) == 0 {
+		unstructured.RemoveNestedField(s.Object, "metadata", "annotations")
+	}
+
+	removeTimestamps(s.Object)
+	return s
+}
+
+// Diff renders the unified diff between the YAML representation of the expected
+// and the live objects, after stripping noisy fields.
+// A nil object is rendered as empty. The diff is empty if the objects are equal.
+func Diff(expected, live *unstructured.Unstructured, opts DiffOptions) (string, error) {
+	if live != nil && expected != nil && opts.OnlyExpectedFields {
+		live = &unstructured.Unstructured{Object: pruneToExpected(expected.Object, live.Object)}
+	}
+
+	e, err := marshalStripped(expected)
+	if err != nil {
+		return "", err
+	}
+	l, err := marshalStripped(live)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(e),
+		B:        difflib.SplitLines(l),
+		FromFile: "expected",
+		ToFile:   "live",
+		Context:  3,
+	})
+}
+
+// DescribeDiff renders the diff between the expected and the live objects for a failure message.
+// Errors rendering the diff are reported in the returned text.
+func DescribeDiff(expected, live *unstructured.Unstructured, opts DiffOptions) string {
+	d, err := Diff(expected, live, opts)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("error computing diff: %v", err)
+	case d == "":
+		return "no differences"
+	default:
+		return d
+	}
+}
+
+// DescribeObject renders the object as YAML for a failure message, after stripping noisy fields
+func DescribeObject(u unstructured.Unstructured) string {
+	d, err := marshalStripped(&u)
+	if err != nil {
+		return fmt.Sprintf("%s %s/%s (error marshaling as yaml: %v)", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+	}
+	return d
+}
+
+func marshalStripped(u *unstructured.Unstructured) (string, error) {
+	if u == nil {
+		return "", nil
+	}
+
+	d, err := yaml.Marshal(StripNoisyFields(*u).Object)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling %s %s/%s as yaml: %w", u.GetKind(), u.GetNamespace(), u.GetName(), err)
+	}
+	return string(d), nil
+}
+
+func removeTimestamps(v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, i := range t {
+			if _, ok := noisyTimestampFields[k]; ok {
+				delete(t, k)
+				continue
+			}
+			removeTimestamps(i)
+		}
+	case []interface{}:
+		for _, i := range t {
+			removeTimestamps(i)
+		}
+	}
+}
+
+// pruneToExpected returns the live object's fields that are present in the expected one.
+// Lists are not pruned.
+func pruneToExpected(expected, live map[string]interface{}) map[string]interface{} {
+	p := map[string]interface{}{}
+	for k, e := range expected {
+		l, ok := live[k]
+		if !ok {
+			continue
+		}
+
+		em, eok := e.(map[string]interface{})
+		lm, lok := l.(map[string]interface{})
+		if eok && lok {
+			p[k] = pruneToExpected(em, lm)
+			continue
+		}
+		p[k] = l
+	}
+	return p
+}
@@ -0,0 +1,106 @@
+package kube_test
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_Diff(t *testing.T) {
+	expected := parseOne(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  state: Complete
+`)
+	live := parseOne(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  namespace: default
+  uid: 0a6c9e0e-5c1e-4d6a-9c57-2f1b7d1e0b1a
+  resourceVersion: "1234"
+  creationTimestamp: "2023-11-20T10:00:00Z"
+  managedFields:
+  - manager: kubectl
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+data:
+  state: Open
+  other: value
+`)
+
+	t.Run("noisy fields are stripped", func(t *testing.T) {
+		t.Parallel()
+
+		s := kube.StripNoisyFields(live)
+		if _, ok := s.Object["metadata"].(map[string]interface{})["annotations"]; ok {
+			t.Errorf("expected empty annotations to be removed, got %v", s.GetAnnotations())
+		}
+		for _, f := range []string{"uid", "resourceVersion", "creationTimestamp", "managedFields"} {
+			if _, ok := s.Object["metadata"].(map[string]interface{})[f]; ok {
+				t.Errorf("expected %s to be removed", f)
+			}
+		}
+	})
+
+	t.Run("unified diff of the whole objects", func(t *testing.T) {
+		t.Parallel()
+
+		d, err := kube.Diff(&expected, &live, kube.DiffOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range []string{"--- expected", "+++ live", "-  state: Complete", "+  state: Open", "+  other: value", "+  namespace: default"} {
+			if !strings.Contains(d, l+"\n") {
+				t.Errorf("expected diff to contain %q, got:\n%s", l, d)
+			}
+		}
+		if strings.Contains(d, "uid") || strings.Contains(d, "managedFields") {
+			t.Errorf("expected noisy fields not to be diffed, got:\n%s", d)
+		}
+	})
+
+	t.Run("unified diff of the expected fields only", func(t *testing.T) {
+		t.Parallel()
+
+		d, err := kube.Diff(&expected, &live, kube.DiffOptions{OnlyExpectedFields: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(d, "+  state: Open\n") {
+			t.Errorf("expected diff to contain the mismatching field, got:\n%s", d)
+		}
+		if strings.Contains(d, "other") || strings.Contains(d, "namespace") {
+			t.Errorf("expected fields not in the expected object not to be diffed, got:\n%s", d)
+		}
+	})
+
+	t.Run("equal objects have no diff", func(t *testing.T) {
+		t.Parallel()
+
+		d, err := kube.Diff(&live, &live, kube.DiffOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != "" {
+			t.Errorf("expected no diff, got:\n%s", d)
+		}
+	})
+}
+
+func parseOne(t *testing.T, spec string) unstructured.Unstructured {
+	t.Helper()
+
+	uu, err := kube.ParseManifests(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uu[0]
+}
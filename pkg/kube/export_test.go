@@ -0,0 +1,12 @@
+package kube
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (k *Kubernetes) PortForwardPod(ctx context.Context, target client.Object, port int) (*corev1.Pod, int, error) {
+	return k.portForwardPod(ctx, target, port)
+}
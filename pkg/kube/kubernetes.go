@@ -3,26 +3,25 @@ package kube
 import (
 	"context"
 	"fmt"
-	"strings"
+	"io"
+	"regexp"
 
-	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	"k8s.io/apimachinery/pkg/types"
-	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
 	cacheddiscovery "k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ Client = &Kubernetes{}
 var _ Client = &NamespacedKubernetes{}
+var _ Client = &MultiNamespacedKubernetes{}
 
 type Client interface {
 	client.WithWatch
@@ -31,11 +30,19 @@ type Client interface {
 	DiscoveryClient() discovery.CachedDiscoveryInterface
 	ClientOptions() client.Options
 	RESTConfig() *rest.Config
+	Refresh()
 
+	Apply(ctx context.Context, objs []unstructured.Unstructured, fieldManager string, force bool) ([]ApplyResult, error)
+	ApplyBundle(ctx context.Context, objs []unstructured.Unstructured, fieldManager string) error
 	DeleteAndWait(ctx context.Context, u unstructured.Unstructured, opts client.DeleteOption) error
 	ParseResources(ctx context.Context, spec string) ([]unstructured.Unstructured, error)
 	WatchForEventOnResourceUnstructured(ctx context.Context, u unstructured.Unstructured, check func(e watch.Event) (bool, error)) (chan error, error)
-	// CreateNamespaceWithLabels(ctx context.Context, namespace string, labels map[string]string) (*corev1.Namespace, error)
+	WaitFor(ctx context.Context, u unstructured.Unstructured, p Predicate) (*unstructured.Unstructured, error)
+
+	Logs(ctx context.Context, pod client.ObjectKey, container string, opts corev1.PodLogOptions) (io.ReadCloser, error)
+	WaitForLogLine(ctx context.Context, pod client.ObjectKey, container string, re *regexp.Regexp) (string, error)
+	Exec(ctx context.Context, pod client.ObjectKey, container string, cmd []string) (*ExecResult, error)
+	PortForward(ctx context.Context, target client.Object, port int) (string, func(), error)
 
 	Livez(ctx context.Context) ([]byte, error)
 	Healthz(ctx context.Context) ([]byte, error)
@@ -47,7 +54,7 @@ type Kubernetes struct {
 	cfg             *rest.Config
 	clientOptions   client.Options
 	discoveryClient discovery.CachedDiscoveryInterface
-	mapper          *restmapper.DeferredDiscoveryRESTMapper
+	mapper          *refreshingRESTMapper
 }
 
 type NamespacedKubernetes struct {
@@ -63,9 +70,9 @@ func NewNamespaced(cfg *rest.Config, opts client.Options, namespace string) (*Na
 	}
 
 	discoveryClient := cacheddiscovery.NewMemCacheClient(cli.Discovery())
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	mapper := newRefreshingRESTMapper(discoveryClient)
 
-	crcli, err := NewNamespacedClient(cfg, opts, namespace)
+	crcli, err := NewNamespacedClient(cfg, withMapper(opts, mapper), namespace)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +88,39 @@ func NewNamespaced(cfg *rest.Config, opts client.Options, namespace string) (*Na
 	}, nil
 }
 
+// MultiNamespacedKubernetes is a Kubernetes client restricted to a set of namespaces,
+// see MultiNamespaceClient
+type MultiNamespacedKubernetes struct {
+	Kubernetes
+
+	Namespaces []string
+}
+
+func NewMultiNamespaced(cfg *rest.Config, opts client.Options, namespaces []string) (*MultiNamespacedKubernetes, error) {
+	cli, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryClient := cacheddiscovery.NewMemCacheClient(cli.Discovery())
+	mapper := newRefreshingRESTMapper(discoveryClient)
+
+	crcli, err := NewMultiNamespaceClient(cfg, withMapper(opts, mapper), namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiNamespacedKubernetes{
+		Kubernetes: Kubernetes{
+			WithWatch:       crcli,
+			mapper:          mapper,
+			cfg:             cfg,
+			clientOptions:   opts,
+			discoveryClient: discoveryClient,
+		},
+		Namespaces: crcli.GetNamespaces(),
+	}, nil
+}
+
 func New(cfg *rest.Config, opts client.Options) (*Kubernetes, error) {
 	cli, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
@@ -88,8 +128,8 @@ func New(cfg *rest.Config, opts client.Options) (*Kubernetes, error) {
 	}
 
 	discoveryClient := cacheddiscovery.NewMemCacheClient(cli.Discovery())
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
-	crcli, err := client.NewWithWatch(cfg, opts)
+	mapper := newRefreshingRESTMapper(discoveryClient)
+	crcli, err := client.NewWithWatch(cfg, withMapper(opts, mapper))
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +143,20 @@ func New(cfg *rest.Config, opts client.Options) (*Kubernetes, error) {
 	}, nil
 }
 
+// WithRESTConfig builds a client like k, preserving its namespace scope, on top of the given rest.Config
+func WithRESTConfig(k Client, cfg *rest.Config) (Client, error) {
+	switch lk := k.(type) {
+	case *Kubernetes:
+		return New(cfg, lk.ClientOptions())
+	case *NamespacedKubernetes:
+		return NewNamespaced(cfg, lk.ClientOptions(), lk.Namespace)
+	case *MultiNamespacedKubernetes:
+		return NewMultiNamespaced(cfg, lk.ClientOptions(), lk.Namespaces)
+	default:
+		return nil, fmt.Errorf("error building client: unsupported client type %T", k)
+	}
+}
+
 func (k *NamespacedKubernetes) ParseResources(ctx context.Context, spec string) ([]unstructured.Unstructured, error) {
 	uu, err := k.Kubernetes.ParseResources(ctx, spec)
 	if err != nil {
@@ -115,6 +169,21 @@ func (k *NamespacedKubernetes) ParseResources(ctx context.Context, spec string)
 	return uu, nil
 }
 
+// ParseResources parses the resources in spec, assigning the ones with no namespace to the default one
+func (k *MultiNamespacedKubernetes) ParseResources(ctx context.Context, spec string) ([]unstructured.Unstructured, error) {
+	uu, err := k.Kubernetes.ParseResources(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, u := range uu {
+		if u.GetNamespace() == "" {
+			u.SetNamespace(k.Namespaces[0])
+		}
+	}
+	return uu, nil
+}
+
 func (k *NamespacedKubernetes) DeleteAndWait(ctx context.Context, u unstructured.Unstructured, opts client.DeleteOption) error {
 	lu := u.DeepCopy()
 	lu.SetNamespace(k.Namespace)
@@ -139,6 +208,35 @@ func (k *Kubernetes) RESTConfig() *rest.Config {
 	return &(*k.cfg)
 }
 
+// Refresh invalidates the cached discovery information and resets the RESTMapper
+// shared with the controller-runtime client, so that kinds installed after the client
+// was built, e.g. by a CRD, can be mapped.
+// Unknown kinds trigger a refresh on their own, see refreshingRESTMapper.
+func (k *Kubernetes) Refresh() {
+	k.mapper.Refresh()
+}
+
+// withMapper returns opts using the given mapper, unless a mapper is already set,
+// so that the controller-runtime client maps kinds with the mapper refreshed by Refresh
+func withMapper(opts client.Options, mapper meta.RESTMapper) client.Options {
+	if opts.Mapper == nil {
+		opts.Mapper = mapper
+	}
+	return opts
+}
+
+// retryOnNoKindMatch runs f and, if it fails because a kind is unknown,
+// refreshes the discovery information and runs it once more
+func (k *Kubernetes) retryOnNoKindMatch(f func() error) error {
+	err := f()
+	if !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	k.Refresh()
+	return f()
+}
+
 func (k *Kubernetes) ClientOptions() client.Options {
 	return k.clientOptions
 }
@@ -151,46 +249,21 @@ func (k *Kubernetes) Healthz(ctx context.Context) ([]byte, error) {
 	return k.DiscoveryClient().RESTClient().Get().AbsPath("/healthz").DoRaw(ctx)
 }
 
+// ParseResources parses the YAML or JSON resources in spec as they are.
+// Parsing is strict, see ParseManifests.
 func (k *Kubernetes) ParseResources(ctx context.Context, spec string) ([]unstructured.Unstructured, error) {
-	uu := []unstructured.Unstructured{}
-	decoder := yamlutil.NewYAMLOrJSONDecoder(strings.NewReader(spec), 100)
-	for {
-		var rawObj runtime.RawExtension
-		if err := decoder.Decode(&rawObj); err != nil {
-			break
-		}
-
-		obj, _, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(rawObj.Raw, nil, nil)
-		if err != nil {
-			return nil, fmt.Errorf("%w: %s", err, rawObj.Raw)
-		}
-
-		unstructuredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
-		if err != nil {
-			return nil, err
-		}
-
-		unstructuredObj := unstructured.Unstructured{Object: unstructuredMap}
-		uu = append(uu, unstructuredObj)
-	}
-
-	return uu, nil
+	return ParseManifests(spec)
 }
 
-// steps
-func (k *Kubernetes) WatchResourceUnstructured(ctx context.Context, u unstructured.Unstructured) (watch.Interface, error) {
-	fs, err := fields.ParseSelector(fmt.Sprintf("metadata.name=%s,metadata.namespace=%s", u.GetName(), u.GetNamespace()))
-	if err != nil {
-		return nil, err
-	}
-
-	o := &client.ListOptions{
-		FieldSelector: fs,
-	}
-	return k.Watch(ctx, &u, o)
+// DeleteAndWait deletes u and waits for it to be gone.
+// If u's kind is unknown, the discovery information is refreshed and the deletion retried once.
+func (k *Kubernetes) DeleteAndWait(ctx context.Context, u unstructured.Unstructured, opts client.DeleteOption) error {
+	return k.retryOnNoKindMatch(func() error {
+		return k.deleteAndWait(ctx, u, opts)
+	})
 }
 
-func (k *Kubernetes) DeleteAndWait(ctx context.Context, u unstructured.Unstructured, opts client.DeleteOption) error {
+func (k *Kubernetes) deleteAndWait(ctx context.Context, u unstructured.Unstructured, opts client.DeleteOption) error {
 	r := u.DeepCopy()
 	t := types.NamespacedName{Namespace: u.GetNamespace(), Name: u.GetName()}
 	if err := k.Get(ctx, t, r, &client.GetOptions{}); err != nil {
@@ -210,45 +283,3 @@ func (k *Kubernetes) DeleteAndWait(ctx context.Context, u unstructured.Unstructu
 
 	return <-we
 }
-
-func (k *Kubernetes) WatchForEventOnResourceUnstructured(ctx context.Context, u unstructured.Unstructured, check func(e watch.Event) (bool, error)) (chan error, error) {
-	m, err := k.mapper.RESTMapping(u.GroupVersionKind().GroupKind(), u.GroupVersionKind().Version)
-	if err != nil {
-		return nil, err
-	}
-
-	we := make(chan error, 1)
-	go func() {
-		defer close(we)
-
-		// watch resource
-		w, err := k.WatchResourceUnstructured(ctx, u)
-		if err != nil {
-			we <- err
-			return
-		}
-		defer w.Stop()
-
-		// check for event to happen
-		for {
-			e, ok := <-w.ResultChan()
-			if !ok {
-				err := kerrors.NewNotFound(m.Resource.GroupResource(), u.GetName())
-				we <- err
-				return
-			}
-
-			ok, err := check(e)
-			if err != nil {
-				we <- err
-				return
-			}
-
-			if ok {
-				break
-			}
-		}
-	}()
-
-	return we, nil
-}
@@ -0,0 +1,102 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeDiscoveryServer serves the example.com/v1 Widget kind once installed
+type fakeDiscoveryServer struct {
+	installed atomic.Bool
+	// number of requests for the API groups
+	discoveries atomic.Int32
+}
+
+func (s *fakeDiscoveryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+
+	gv := metav1.GroupVersionForDiscovery{GroupVersion: "example.com/v1", Version: "v1"}
+	switch {
+	case r.URL.Path == "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+	case r.URL.Path == "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"}, GroupVersion: "v1"})
+	case r.URL.Path == "/apis":
+		s.discoveries.Add(1)
+		gl := metav1.APIGroupList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"}}
+		if s.installed.Load() {
+			gl.Groups = []metav1.APIGroup{{Name: "example.com", Versions: []metav1.GroupVersionForDiscovery{gv}, PreferredVersion: gv}}
+		}
+		_ = e.Encode(gl)
+	case r.URL.Path == "/apis/example.com/v1" && s.installed.Load():
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "widgets", Namespaced: true, Kind: "Widget", Verbs: []string{"get"}},
+			},
+		})
+	case r.URL.Path == "/apis/example.com/v1/namespaces/test/widgets/w" && s.installed.Load():
+		_ = e.Encode(map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"metadata":   map[string]interface{}{"name": "w", "namespace": "test"},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_ = e.Encode(metav1.Status{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"}, Status: metav1.StatusFailure, Reason: metav1.StatusReasonNotFound, Code: http.StatusNotFound})
+	}
+}
+
+func Test_Refresh(t *testing.T) {
+	s := &fakeDiscoveryServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	k, err := kube.New(cfg, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	get := func() error {
+		u := unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"})
+		return k.Get(ctx, client.ObjectKey{Namespace: "test", Name: "w"}, &u)
+	}
+
+	if err := get(); !meta.IsNoMatchError(err) {
+		t.Fatalf("expected no match error before the kind is installed, got %v", err)
+	}
+
+	// unknown kinds refresh the discovery information
+	s.installed.Store(true)
+	if err := get(); err != nil {
+		t.Fatalf("expected installed kind to be mapped, got %v", err)
+	}
+
+	// the client's RESTMapper is the one reset by Refresh
+	n := s.discoveries.Load()
+	k.Refresh()
+	if _, err := k.RESTMapper().RESTMapping(schema.GroupKind{Group: "example.com", Kind: "Widget"}, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.discoveries.Load(); d <= n {
+		t.Errorf("expected the API groups to be discovered again after refreshing")
+	}
+}
@@ -0,0 +1,50 @@
+package kube
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+)
+
+// refreshingRESTMapper is a discovery based RESTMapper that refreshes the discovery
+// information once when a kind is unknown, e.g. because it has been installed by a CRD
+// after the mapper was built
+type refreshingRESTMapper struct {
+	*restmapper.DeferredDiscoveryRESTMapper
+
+	discoveryClient discovery.CachedDiscoveryInterface
+}
+
+func newRefreshingRESTMapper(discoveryClient discovery.CachedDiscoveryInterface) *refreshingRESTMapper {
+	return &refreshingRESTMapper{
+		DeferredDiscoveryRESTMapper: restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient),
+		discoveryClient:             discoveryClient,
+	}
+}
+
+// Refresh invalidates the cached discovery information and resets the mapper
+func (m *refreshingRESTMapper) Refresh() {
+	m.discoveryClient.Invalidate()
+	m.Reset()
+}
+
+func (m *refreshingRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	r, err := m.DeferredDiscoveryRESTMapper.RESTMapping(gk, versions...)
+	if !meta.IsNoMatchError(err) {
+		return r, err
+	}
+
+	m.Refresh()
+	return m.DeferredDiscoveryRESTMapper.RESTMapping(gk, versions...)
+}
+
+func (m *refreshingRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	rr, err := m.DeferredDiscoveryRESTMapper.RESTMappings(gk, versions...)
+	if !meta.IsNoMatchError(err) {
+		return rr, err
+	}
+
+	m.Refresh()
+	return m.DeferredDiscoveryRESTMapper.RESTMappings(gk, versions...)
+}
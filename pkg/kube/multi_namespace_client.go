@@ -0,0 +1,347 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var ErrNamespaceNotAllowed error = fmt.Errorf("error namespace not allowed in multi-namespace client")
+
+var _ client.WithWatch = &MultiNamespaceClient{}
+var _ client.SubResourceClient = &multiNamespaceSubResourceClient{}
+
+// MultiNamespaceClient restricts operations to a declared set of namespaces.
+//
+// Objects with no namespace are assigned to the default namespace, i.e. the first one of the set.
+// Get, List, Watch and DeleteAllOf with no namespace are routed to all the namespaces of the set,
+// merging the results. Operations on other namespaces and on cluster-scoped
+// resources are rejected.
+type MultiNamespaceClient struct {
+	cli client.WithWatch
+
+	namespaces []string
+}
+
+func NewMultiNamespaceClient(
+	cfg *rest.Config,
+	opts client.Options,
+	namespaces []string,
+) (*MultiNamespaceClient, error) {
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("%w: at least one namespace is required", ErrNamespaceNotAllowed)
+	}
+
+	cli, err := client.NewWithWatch(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiNamespaceClient{
+		cli:        cli,
+		namespaces: namespaces,
+	}, nil
+}
+
+func (c *MultiNamespaceClient) GetNamespaces() []string { return slices.Clone(c.namespaces) }
+
+// GetNamespace returns the default namespace
+func (c *MultiNamespaceClient) GetNamespace() string { return c.namespaces[0] }
+
+// Implement client.Writer
+
+func (c *MultiNamespaceClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.cli.Create(ctx, obj, opts...)
+}
+
+func (c *MultiNamespaceClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.cli.Delete(ctx, obj, opts...)
+}
+
+func (c *MultiNamespaceClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.cli.Update(ctx, obj, opts...)
+}
+
+func (c *MultiNamespaceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.cli.Patch(ctx, obj, patch, opts...)
+}
+
+// DeleteAllOf deletes all objects of the given type matching the given options
+// in the requested namespace, or in all the namespaces of the set.
+func (c *MultiNamespaceClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := checkNamespaced(c.cli, obj); err != nil {
+		return err
+	}
+
+	o := client.DeleteAllOfOptions{}
+	o.ApplyOptions(opts)
+	nn, err := c.targetNamespaces(o.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nn {
+		if err := c.cli.DeleteAllOf(ctx, obj, append(opts, client.InNamespace(n))...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Implement client.Reader
+
+// Get retrieves the object from the requested namespace or, if none, from the first namespace of the set it is found in.
+func (c *MultiNamespaceClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := checkNamespaced(c.cli, obj); err != nil {
+		return err
+	}
+
+	nn, err := c.targetNamespaces(key.Namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nn {
+		key.Namespace = n
+		err = c.cli.Get(ctx, key, obj, opts...)
+		if !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// List retrieves the objects from the requested namespace, or from all the namespaces of the set
+func (c *MultiNamespaceClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := checkNamespaced(c.cli, list); err != nil {
+		return err
+	}
+
+	o := client.ListOptions{}
+	o.ApplyOptions(opts)
+	nn, err := c.targetNamespaces(o.Namespace)
+	if err != nil {
+		return err
+	}
+	if len(nn) == 1 {
+		return c.cli.List(ctx, list, append(opts, client.InNamespace(nn[0]))...)
+	}
+
+	ii := []runtime.Object{}
+	for _, n := range nn {
+		nl, ok := list.DeepCopyObject().(client.ObjectList)
+		if !ok {
+			return fmt.Errorf("error copying list %T", list)
+		}
+		if err := c.cli.List(ctx, nl, append(opts, client.InNamespace(n))...); err != nil {
+			return err
+		}
+
+		ni, err := meta.ExtractList(nl)
+		if err != nil {
+			return err
+		}
+		ii = append(ii, ni...)
+	}
+
+	// results of multiple lists can not be resumed
+	list.SetResourceVersion("")
+	list.SetContinue("")
+	return meta.SetList(list, ii)
+}
+
+// Implement Watch
+
+// Watch watches the requested namespace, or all the namespaces of the set merging their events
+func (c *MultiNamespaceClient) Watch(ctx context.Context, obj client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	if err := checkNamespaced(c.cli, obj); err != nil {
+		return nil, err
+	}
+
+	o := client.ListOptions{}
+	o.ApplyOptions(opts)
+	nn, err := c.targetNamespaces(o.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ww := make([]watch.Interface, 0, len(nn))
+	for _, n := range nn {
+		w, err := c.cli.Watch(ctx, obj, append(opts, client.InNamespace(n))...)
+		if err != nil {
+			for _, w := range ww {
+				w.Stop()
+			}
+			return nil, err
+		}
+		ww = append(ww, w)
+	}
+	return newMergedWatch(ww), nil
+}
+
+// other interfaces
+func (c *MultiNamespaceClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *MultiNamespaceClient) SubResource(subResource string) client.SubResourceClient {
+	return &multiNamespaceSubResourceClient{client: c.cli.SubResource(subResource), parent: c}
+}
+
+// Scheme returns the scheme this client is using.
+func (c *MultiNamespaceClient) Scheme() *runtime.Scheme {
+	return c.cli.Scheme()
+}
+
+// RESTMapper returns the rest this client is using.
+func (c *MultiNamespaceClient) RESTMapper() meta.RESTMapper {
+	return c.cli.RESTMapper()
+}
+
+// GroupVersionKindFor returns the GroupVersionKind for the given object.
+func (c *MultiNamespaceClient) GroupVersionKindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return c.cli.GroupVersionKindFor(obj)
+}
+
+// IsObjectNamespaced returns true if the GroupVersionKind of the object is namespaced.
+func (c *MultiNamespaceClient) IsObjectNamespaced(obj runtime.Object) (bool, error) {
+	return c.cli.IsObjectNamespaced(obj)
+}
+
+// scopeObject checks the object is namespaced and in one of the allowed namespaces.
+// Objects with no namespace are assigned to the default one.
+func (c *MultiNamespaceClient) scopeObject(obj client.Object) error {
+	if err := checkNamespaced(c.cli, obj); err != nil {
+		return err
+	}
+
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(c.GetNamespace())
+		return nil
+	}
+	if !slices.Contains(c.namespaces, obj.GetNamespace()) {
+		return fmt.Errorf("%w: %s, allowed ones are %v", ErrNamespaceNotAllowed, obj.GetNamespace(), c.namespaces)
+	}
+	return nil
+}
+
+// targetNamespaces returns the namespaces an operation requested for namespace has to be routed to
+func (c *MultiNamespaceClient) targetNamespaces(namespace string) ([]string, error) {
+	switch {
+	case namespace == "":
+		return c.namespaces, nil
+	case slices.Contains(c.namespaces, namespace):
+		return []string{namespace}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s, allowed ones are %v", ErrNamespaceNotAllowed, namespace, c.namespaces)
+	}
+}
+
+// type multiNamespaceSubResourceClient
+type multiNamespaceSubResourceClient struct {
+	client client.SubResourceClient
+
+	parent *MultiNamespaceClient
+}
+
+func (c *multiNamespaceSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.client.Get(ctx, obj, subResource, opts...)
+}
+
+func (c *multiNamespaceSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.client.Create(ctx, obj, subResource, opts...)
+}
+
+func (c *multiNamespaceSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.client.Update(ctx, obj, opts...)
+}
+
+func (c *multiNamespaceSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
+	return c.client.Patch(ctx, obj, patch, opts...)
+}
+
+// mergedWatch merges the events of multiple watches into a single stream.
+// The stream is closed as soon as any of them is closed, stopping the others,
+// so that clients notice the namespace whose events stopped and watch again.
+type mergedWatch struct {
+	ww     []watch.Interface
+	result chan watch.Event
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func newMergedWatch(ww []watch.Interface) *mergedWatch {
+	m := &mergedWatch{
+		ww:     ww,
+		result: make(chan watch.Event),
+		stop:   make(chan struct{}),
+	}
+
+	wg := sync.WaitGroup{}
+	for _, w := range ww {
+		wg.Add(1)
+		go func(w watch.Interface) {
+			defer wg.Done()
+			defer m.Stop()
+			for e := range w.ResultChan() {
+				select {
+				case m.result <- e:
+				case <-m.stop:
+					return
+				}
+			}
+		}(w)
+	}
+
+	go func() {
+		wg.Wait()
+		close(m.result)
+	}()
+	return m
+}
+
+func (m *mergedWatch) ResultChan() <-chan watch.Event {
+	return m.result
+}
+
+func (m *mergedWatch) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+		for _, w := range m.ww {
+			w.Stop()
+		}
+	})
+}
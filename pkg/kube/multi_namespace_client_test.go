@@ -0,0 +1,191 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeMultiNamespaceServer serves one ConfigMap named after the namespace for each namespace
+// and, on watches, streams an ADDED event for it. Watches stay open until the client stops them,
+// except in the closing namespace.
+type fakeMultiNamespaceServer struct {
+	fakeScopedServer
+
+	closing string
+}
+
+func (s *fakeMultiNamespaceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p, ok := strings.CutPrefix(r.URL.Path, "/api/v1/namespaces/")
+	if !ok || r.Method != http.MethodGet {
+		s.fakeScopedServer.ServeHTTP(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.paths = append(s.paths, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	ns, rest, _ := strings.Cut(p, "/")
+	cm := corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: ns, Namespace: ns},
+	}
+	switch {
+	case r.URL.Query().Get("watch") == "true":
+		_ = e.Encode(metav1.WatchEvent{Type: "ADDED", Object: rawJSON(cm)})
+		w.(http.Flusher).Flush()
+		if ns != s.closing {
+			<-r.Context().Done()
+		}
+	case rest == "configmaps":
+		_ = e.Encode(corev1.ConfigMapList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMapList"},
+			Items:    []corev1.ConfigMap{cm},
+		})
+	case rest == "configmaps/"+ns:
+		_ = e.Encode(cm)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_ = e.Encode(kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, rest).Status())
+	}
+}
+
+func Test_MultiNamespaceClient(t *testing.T) {
+	s := &fakeMultiNamespaceServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	c, err := kube.NewMultiNamespaceClient(cfg, client.Options{}, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Run("list merges all namespaces", func(t *testing.T) {
+		l := corev1.ConfigMapList{}
+		if err := c.List(ctx, &l); err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Items) != 2 || l.Items[0].Namespace != "a" || l.Items[1].Namespace != "b" {
+			t.Errorf("expected ConfigMaps from namespaces a and b, got %v", l.Items)
+		}
+	})
+
+	t.Run("get searches all namespaces", func(t *testing.T) {
+		cm := corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Name: "b"}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		if cm.Namespace != "b" {
+			t.Errorf("expected ConfigMap from namespace b, got %s", cm.Namespace)
+		}
+
+		err := c.Get(ctx, client.ObjectKey{Name: "c"}, &cm)
+		if !kerrors.IsNotFound(err) {
+			t.Errorf("expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("watch merges all namespaces", func(t *testing.T) {
+		w, err := c.Watch(ctx, &corev1.ConfigMapList{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Stop()
+
+		nn := []string{}
+		for len(nn) < 2 {
+			e, ok := <-w.ResultChan()
+			if !ok {
+				t.Fatalf("expected watch to stay open, got events from %v", nn)
+			}
+			cm, ok := e.Object.(*corev1.ConfigMap)
+			if !ok {
+				t.Fatalf("expected ConfigMap, got %T", e.Object)
+			}
+			nn = append(nn, cm.Namespace)
+		}
+		sort.Strings(nn)
+		if len(nn) != 2 || nn[0] != "a" || nn[1] != "b" {
+			t.Errorf("expected events from namespaces a and b, got %v", nn)
+		}
+	})
+
+	t.Run("objects with no namespace go to the default one", func(t *testing.T) {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}}
+		if err := c.Create(ctx, cm); err != nil {
+			t.Fatal(err)
+		}
+		if cm.Namespace != "a" {
+			t.Errorf("expected ConfigMap to be created in namespace a, got %s", cm.Namespace)
+		}
+	})
+
+	t.Run("operations outside the set are rejected", func(t *testing.T) {
+		for _, err := range []error{
+			c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "other"}}),
+			c.Get(ctx, client.ObjectKey{Namespace: "other", Name: "cm"}, &corev1.ConfigMap{}),
+			c.List(ctx, &corev1.ConfigMapList{}, client.InNamespace("other")),
+			c.Status().Update(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "other"}}),
+		} {
+			if !errors.Is(err, kube.ErrNamespaceNotAllowed) {
+				t.Errorf("expected ErrNamespaceNotAllowed, got %v", err)
+			}
+		}
+
+		if err := c.List(ctx, &corev1.NamespaceList{}); !errors.Is(err, kube.ErrClusterScopedNotAllowed) {
+			t.Errorf("expected ErrClusterScopedNotAllowed, got %v", err)
+		}
+	})
+}
+
+func Test_MultiNamespaceClient_WatchClosed(t *testing.T) {
+	s := &fakeMultiNamespaceServer{closing: "b"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	c, err := kube.NewMultiNamespaceClient(cfg, client.Options{}, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := c.Watch(context.Background(), &corev1.ConfigMapList{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// the watch of namespace b ends after its event, closing the merged one
+	// while the watch of namespace a is still open
+	tc := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+		case <-tc:
+			t.Fatal("expected watch to be closed when the watch of a namespace ends")
+		}
+	}
+}
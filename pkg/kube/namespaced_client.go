@@ -2,6 +2,8 @@ package kube
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -11,6 +13,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+var ErrClusterScopedNotAllowed error = fmt.Errorf("error cluster-scoped resources are not allowed in namespaced client")
+
 var _ client.WithWatch = &NamespacedClient{}
 var _ client.SubResourceClient = &subResourceClient{}
 
@@ -44,33 +48,44 @@ func (c *NamespacedClient) GetNamespace() string { return c.namespace }
 // Create saves the object obj in the Kubernetes cluster. obj must be a
 // struct pointer so that obj can be updated with the content returned by the Server.
 func (c *NamespacedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.cli.Create(ctx, obj, opts...)
 }
 
 // Delete deletes the given obj from Kubernetes cluster.
 func (c *NamespacedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.cli.Delete(ctx, obj, opts...)
 }
 
 // Update updates the given obj in the Kubernetes cluster. obj must be a
 // struct pointer so that obj can be updated with the content returned by the Server.
 func (c *NamespacedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.cli.Update(ctx, obj, opts...)
 }
 
 // Patch patches the given obj in the Kubernetes cluster. obj must be a
 // struct pointer so that obj can be updated with the content returned by the Server.
 func (c *NamespacedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.cli.Patch(ctx, obj, patch, opts...)
 }
 
 // DeleteAllOf deletes all objects of the given type matching the given options.
 func (c *NamespacedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.scopeObject(obj); err != nil {
+		return err
+	}
+	opts = append(opts, client.InNamespace(c.namespace))
 	return c.cli.DeleteAllOf(ctx, obj, opts...)
 }
 
@@ -80,6 +95,9 @@ func (c *NamespacedClient) DeleteAllOf(ctx context.Context, obj client.Object, o
 // obj must be a struct pointer so that obj can be updated with the response
 // returned by the Server.
 func (c *NamespacedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if err := c.checkNamespaced(obj); err != nil {
+		return err
+	}
 	key.Namespace = c.namespace
 	return c.cli.Get(ctx, key, obj, opts...)
 }
@@ -97,6 +115,9 @@ func (o *namespacedListOptions) ApplyToList(l *client.ListOptions) {
 // successful call, Items field in the list will be populated with the
 // result returned from the server.
 func (c *NamespacedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.checkNamespaced(list); err != nil {
+		return err
+	}
 	opts = append(opts, &namespacedListOptions{namespace: c.namespace})
 	return c.cli.List(ctx, list, opts...)
 }
@@ -104,6 +125,10 @@ func (c *NamespacedClient) List(ctx context.Context, list client.ObjectList, opt
 // Implement Watch
 
 func (c *NamespacedClient) Watch(ctx context.Context, obj client.ObjectList, opts ...client.ListOption) (watch.Interface, error) {
+	if err := c.checkNamespaced(obj); err != nil {
+		return nil, err
+	}
+	opts = append(opts, &namespacedListOptions{namespace: c.namespace})
 	return c.cli.Watch(ctx, obj, opts...)
 }
 
@@ -151,7 +176,7 @@ func (c *NamespacedClient) Status() client.SubResourceWriter {
 //     scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: 2}}
 //     c.SubResourceClient("scale").Update(ctx, dep, client.WithSubResourceBody(scale))
 func (c *NamespacedClient) SubResource(subResource string) client.SubResourceClient {
-	return &subResourceClient{client: c.cli.SubResource(subResource)}
+	return &subResourceClient{client: c.cli.SubResource(subResource), parent: c}
 }
 
 // Scheme returns the scheme this client is using.
@@ -178,18 +203,22 @@ func (c *NamespacedClient) IsObjectNamespaced(obj runtime.Object) (bool, error)
 type subResourceClient struct {
 	client client.SubResourceClient
 
-	namespace string
+	parent *NamespacedClient
 }
 
 func (c *subResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.client.Get(ctx, obj, subResource, opts...)
 }
 
 // Create saves the subResource object in the Kubernetes cluster. obj must be a
 // struct pointer so that obj can be updated with the content returned by the Server.
 func (c *subResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.client.Create(ctx, obj, subResource, opts...)
 }
 
@@ -197,7 +226,9 @@ func (c *subResourceClient) Create(ctx context.Context, obj client.Object, subRe
 // given obj. obj must be a struct pointer so that obj can be updated
 // with the content returned by the Server.
 func (c *subResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.client.Update(ctx, obj, opts...)
 }
 
@@ -205,6 +236,42 @@ func (c *subResourceClient) Update(ctx context.Context, obj client.Object, opts
 // pointer so that obj can be updated with the content returned by the
 // Server.
 func (c *subResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
-	obj.SetNamespace(c.namespace)
+	if err := c.parent.scopeObject(obj); err != nil {
+		return err
+	}
 	return c.client.Patch(ctx, obj, patch, opts...)
 }
+
+// scopeObject checks the object is namespaced and sets the client's namespace on it
+func (c *NamespacedClient) scopeObject(obj client.Object) error {
+	if err := c.checkNamespaced(obj); err != nil {
+		return err
+	}
+	obj.SetNamespace(c.namespace)
+	return nil
+}
+
+// checkNamespaced returns an error wrapping ErrClusterScopedNotAllowed if the
+// object, or the items of the list, are cluster-scoped
+func (c *NamespacedClient) checkNamespaced(obj runtime.Object) error {
+	return checkNamespaced(c.cli, obj)
+}
+
+func checkNamespaced(cli client.Client, obj runtime.Object) error {
+	gvk, err := cli.GroupVersionKindFor(obj)
+	if err != nil {
+		return err
+	}
+	if meta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+
+	m, err := cli.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	if m.Scope.Name() != meta.RESTScopeNameNamespace {
+		return fmt.Errorf("%w: %s", ErrClusterScopedNotAllowed, gvk.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeScopedServer serves namespaced ConfigMaps and cluster-scoped Namespaces,
+// echoing back request bodies and recording the requested paths
+type fakeScopedServer struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *fakeScopedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+	case "/apis":
+		_ = e.Encode(metav1.APIGroupList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"}})
+	case "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: []string{"create", "get", "list", "watch", "update"}},
+				{Name: "namespaces", Namespaced: false, Kind: "Namespace", Verbs: []string{"create", "get", "list", "watch"}},
+			},
+		})
+	default:
+		s.paths = append(s.paths, r.Method+" "+r.URL.Path)
+		if r.URL.Query().Get("watch") == "true" {
+			return
+		}
+		if r.Body != nil {
+			b, _ := io.ReadAll(r.Body)
+			if len(b) > 0 {
+				_, _ = w.Write(b)
+				return
+			}
+		}
+		_ = e.Encode(corev1.ConfigMap{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}})
+	}
+}
+
+func Test_NamespacedClient(t *testing.T) {
+	s := &fakeScopedServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	c, err := kube.NewNamespacedClient(cfg, client.Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "other"}}
+	if err := c.Create(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Status().Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DeleteAllOf(ctx, &corev1.ConfigMap{}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := c.Watch(ctx, &corev1.ConfigMapList{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Stop()
+
+	for _, err := range []error{
+		c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}),
+		c.Get(ctx, client.ObjectKey{Name: "ns"}, &corev1.Namespace{}),
+		c.List(ctx, &corev1.NamespaceList{}),
+	} {
+		if !errors.Is(err, kube.ErrClusterScopedNotAllowed) {
+			t.Errorf("expected ErrClusterScopedNotAllowed, got %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expected := []string{
+		"POST /api/v1/namespaces/test/configmaps",
+		"PUT /api/v1/namespaces/test/configmaps/cm/status",
+		"DELETE /api/v1/namespaces/test/configmaps",
+		"GET /api/v1/namespaces/test/configmaps",
+	}
+	if len(s.paths) != len(expected) {
+		t.Fatalf("expected requests %v, got %v", expected, s.paths)
+	}
+	for i := range expected {
+		if s.paths[i] != expected[i] {
+			t.Errorf("expected request %s, got %s", expected[i], s.paths[i])
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CreateNamespacesWithLabels creates the given namespaces, all with the same labels.
+// It stops at the first failure, returning the namespaces created so far.
+func CreateNamespacesWithLabels(ctx context.Context, k client.Client, namespaces []string, labels map[string]string) ([]corev1.Namespace, error) {
+	nn := make([]corev1.Namespace, 0, len(namespaces))
+	for _, n := range namespaces {
+		ns := corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   n,
+				Labels: maps.Clone(labels),
+			},
+		}
+		if err := k.Create(ctx, &ns, &client.CreateOptions{}); err != nil {
+			return nn, fmt.Errorf("error creating namespace %s: %w", n, err)
+		}
+		nn = append(nn, ns)
+	}
+	return nn, nil
+}
@@ -0,0 +1,203 @@
+package kube
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+var ErrInvalidManifest error = fmt.Errorf("error invalid manifest")
+
+// matches the line reported in YAML syntax errors
+var yamlErrorLine = regexp.MustCompile(`yaml: line (\d+):`)
+
+// ParseError is returned when a document of a manifest can not be parsed
+type ParseError struct {
+	// 1-based index of the document in the manifest
+	Document int
+	// 1-based line of the manifest the error refers to
+	Line int
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing document %d at line %d: %v", e.Document, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// document is a YAML document of a manifest
+type document struct {
+	content string
+	// 1-based line of the manifest the document starts at
+	line int
+}
+
+// ParseManifests strictly parses the YAML or JSON documents in spec.
+//
+// Empty documents are skipped, the items of `List` kinds are returned as separate resources.
+// Documents that are not valid YAML or that miss apiVersion, kind or metadata.name
+// make the parsing fail with a *ParseError.
+func ParseManifests(spec string) ([]unstructured.Unstructured, error) {
+	return ParseManifestsWithOptions(spec, ParseOptions{})
+}
+
+// ParseOptions relaxes the checks performed by ParseManifestsWithOptions
+type ParseOptions struct {
+	// If true, documents with no metadata.name are accepted,
+	// e.g. to select all the resources of a kind
+	AllowMissingName bool
+}
+
+// ParseManifestsWithOptions parses the YAML or JSON documents in spec like ParseManifests,
+// relaxing the checks as configured in opts
+func ParseManifestsWithOptions(spec string, opts ParseOptions) ([]unstructured.Unstructured, error) {
+	uu := []unstructured.Unstructured{}
+	for i, d := range splitDocuments(spec) {
+		du, err := parseDocument(d, opts)
+		if err != nil {
+			return nil, &ParseError{Document: i + 1, Line: d.line + errorLine(err) - 1, Err: err}
+		}
+		uu = append(uu, du...)
+	}
+	return uu, nil
+}
+
+// splitDocuments splits spec on YAML document separators, keeping track of the line
+// each document starts at
+func splitDocuments(spec string) []document {
+	dd := []document{}
+	d, b := document{line: 1}, strings.Builder{}
+
+	s := bufio.NewScanner(strings.NewReader(spec))
+	s.Buffer(make([]byte, 0, 64*1024), len(spec)+1)
+	for l := 1; s.Scan(); l++ {
+		t := s.Text()
+		if r, ok := cutDocumentSeparator(t); ok {
+			d.content = b.String()
+			dd = append(dd, d)
+			d, b = document{line: l + 1}, strings.Builder{}
+			if r == "" {
+				continue
+			}
+
+			// content following the separator starts the next document
+			d.line, t = l, r
+		}
+
+		b.WriteString(t)
+		b.WriteByte('\n')
+	}
+
+	d.content = b.String()
+	return append(dd, d)
+}
+
+// cutDocumentSeparator returns the content following the document separator the line starts with, if any
+func cutDocumentSeparator(line string) (string, bool) {
+	r, ok := strings.CutPrefix(strings.TrimRight(line, " \t"), "---")
+	if !ok || (r != "" && r[0] != ' ' && r[0] != '\t') {
+		return "", false
+	}
+	return strings.TrimLeft(r, " \t"), true
+}
+
+func parseDocument(d document, opts ParseOptions) ([]unstructured.Unstructured, error) {
+	j, err := yaml.YAMLToJSON([]byte(d.content))
+	if err != nil {
+		return nil, err
+	}
+
+	// empty document or comments only
+	if s := strings.TrimSpace(string(j)); s == "null" || s == "" {
+		return nil, nil
+	}
+
+	var o interface{}
+	if err := utiljson.Unmarshal(j, &o); err != nil {
+		return nil, err
+	}
+	m, ok := o.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: expected an object, found %T", ErrInvalidManifest, o)
+	}
+
+	u := unstructured.Unstructured{Object: m}
+	if u.IsList() {
+		return parseList(u, opts)
+	}
+
+	if err := validateResource(u, opts); err != nil {
+		return nil, err
+	}
+	return []unstructured.Unstructured{u}, nil
+}
+
+func parseList(u unstructured.Unstructured, opts ParseOptions) ([]unstructured.Unstructured, error) {
+	if err := validateTypeMeta(u); err != nil {
+		return nil, err
+	}
+
+	ii, _, err := unstructured.NestedSlice(u.Object, "items")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+
+	uu := make([]unstructured.Unstructured, 0, len(ii))
+	for i, it := range ii {
+		m, ok := it.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: item %d of %s: expected an object, found %T", ErrInvalidManifest, i, u.GetKind(), it)
+		}
+
+		iu := unstructured.Unstructured{Object: m}
+		if err := validateResource(iu, opts); err != nil {
+			return nil, fmt.Errorf("item %d of %s: %w", i, u.GetKind(), err)
+		}
+		uu = append(uu, iu)
+	}
+	return uu, nil
+}
+
+func validateResource(u unstructured.Unstructured, opts ParseOptions) error {
+	if err := validateTypeMeta(u); err != nil {
+		return err
+	}
+	if !opts.AllowMissingName && u.GetName() == "" && u.GetGenerateName() == "" {
+		return fmt.Errorf("%w: metadata.name is missing in %s", ErrInvalidManifest, u.GetKind())
+	}
+	return nil
+}
+
+func validateTypeMeta(u unstructured.Unstructured) error {
+	if u.GetAPIVersion() == "" {
+		return fmt.Errorf("%w: apiVersion is missing", ErrInvalidManifest)
+	}
+	if u.GetKind() == "" {
+		return fmt.Errorf("%w: kind is missing", ErrInvalidManifest)
+	}
+	return nil
+}
+
+// errorLine returns the document's line a YAML syntax error refers to, or 1
+func errorLine(err error) int {
+	m := yamlErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 1
+	}
+
+	l, err := strconv.Atoi(m[1])
+	if err != nil || l < 1 {
+		return 1
+	}
+	return l
+}
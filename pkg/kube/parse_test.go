@@ -0,0 +1,147 @@
+package kube_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_ParseManifests(t *testing.T) {
+	t.Run("parses multiple documents skipping empty ones", func(t *testing.T) {
+		t.Parallel()
+
+		spec := `---
+# a comment only document
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+data:
+  replicas: "1"
+---
+
+---
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "second"}}
+`
+		uu, err := kube.ParseManifests(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uu) != 2 {
+			t.Fatalf("expected 2 resources, got %d", len(uu))
+		}
+		if uu[0].GetName() != "first" || uu[1].GetName() != "second" {
+			t.Errorf("unexpected resources parsed: %s, %s", uu[0].GetName(), uu[1].GetName())
+		}
+	})
+
+	t.Run("parses content following document separators", func(t *testing.T) {
+		t.Parallel()
+
+		spec := `--- # first
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+--- {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "second"}}
+---	{apiVersion: v1, kind: Secret, metadata: {name: third}}
+`
+		uu, err := kube.ParseManifests(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uu) != 3 {
+			t.Fatalf("expected 3 resources, got %d", len(uu))
+		}
+		for i, n := range []string{"first", "second", "third"} {
+			if uu[i].GetName() != n {
+				t.Errorf("expected resource %s, got %s", n, uu[i].GetName())
+			}
+		}
+
+		_, err = kube.ParseManifests("--- {apiVersion: v1, kind: Secret}\n")
+		pe := &kube.ParseError{}
+		if !errors.As(err, &pe) || pe.Line != 1 {
+			t.Errorf("expected ParseError at line 1, got %v", err)
+		}
+	})
+
+	t.Run("expands List kinds", func(t *testing.T) {
+		t.Parallel()
+
+		spec := `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: first
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: second
+`
+		uu, err := kube.ParseManifests(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uu) != 2 {
+			t.Fatalf("expected 2 resources, got %d", len(uu))
+		}
+	})
+
+	t.Run("reports document and line of syntax errors", func(t *testing.T) {
+		t.Parallel()
+
+		spec := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: second
+   namespace: wrong-indentation
+`
+		_, err := kube.ParseManifests(spec)
+		pe := &kube.ParseError{}
+		if !errors.As(err, &pe) {
+			t.Fatalf("expected ParseError, got %v", err)
+		}
+		if pe.Document != 2 || pe.Line != 10 {
+			t.Errorf("expected error at document 2 line 10, got document %d line %d: %v", pe.Document, pe.Line, pe)
+		}
+	})
+
+	t.Run("accepts resources with no name if allowed", func(t *testing.T) {
+		t.Parallel()
+
+		uu, err := kube.ParseManifestsWithOptions("apiVersion: v1\nkind: ConfigMap", kube.ParseOptions{AllowMissingName: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uu) != 1 {
+			t.Fatalf("expected 1 resource, got %d", len(uu))
+		}
+	})
+
+	t.Run("rejects incomplete resources", func(t *testing.T) {
+		t.Parallel()
+
+		for n, spec := range map[string]string{
+			"missing apiVersion": "kind: ConfigMap\nmetadata:\n  name: cm",
+			"missing kind":       "apiVersion: v1\nmetadata:\n  name: cm",
+			"missing name":       "apiVersion: v1\nkind: ConfigMap\ndata: {}",
+			"not an object":      "- apiVersion: v1",
+			"invalid list item":  "apiVersion: v1\nkind: List\nitems:\n- kind: ConfigMap",
+		} {
+			if _, err := kube.ParseManifests(spec); !errors.Is(err, kube.ErrInvalidManifest) {
+				t.Errorf("%s: expected ErrInvalidManifest, got %v", n, err)
+			}
+		}
+	})
+}
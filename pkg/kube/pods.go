@@ -0,0 +1,316 @@
+package kube
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/poll"
+)
+
+var (
+	ErrLogLineNotFound   error = fmt.Errorf("error no log line matching")
+	ErrNoReadyPod        error = fmt.Errorf("error no ready pod")
+	ErrPortNotFound      error = fmt.Errorf("error port not found")
+	ErrUnsupportedTarget error = fmt.Errorf("error unsupported port-forward target")
+)
+
+// ExecResult is the output of a command executed in a container
+type ExecResult struct {
+	Stdout []byte
+	Stderr []byte
+}
+
+// Logs returns the logs of the pod's container. With opts.Follow the logs are streamed
+// until the returned reader is closed or ctx is done.
+func (k *Kubernetes) Logs(ctx context.Context, pod client.ObjectKey, container string, opts corev1.PodLogOptions) (io.ReadCloser, error) {
+	cli, err := k.Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Container = container
+	return cli.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &opts).Stream(ctx)
+}
+
+// WaitForLogLine follows the logs of the pod's container until a line matches re, and returns it.
+// Streams that end, e.g. because the container is not started yet or restarted, are opened again.
+func (k *Kubernetes) WaitForLogLine(ctx context.Context, pod client.ObjectKey, container string, re *regexp.Regexp) (string, error) {
+	return waitForLogLine(ctx, k, pod, container, re)
+}
+
+func waitForLogLine(ctx context.Context, k Client, pod client.ObjectKey, container string, re *regexp.Regexp) (string, error) {
+	var l string
+	err := poll.Do(ctx, 2*time.Second, func(ctx context.Context) error {
+		r, err := k.Logs(ctx, pod, container, corev1.PodLogOptions{Follow: true})
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			if re.MatchString(s.Text()) {
+				l = s.Text()
+				return nil
+			}
+		}
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w %q in logs of %s container %s", ErrLogLineNotFound, re, pod, container)
+	})
+	return l, err
+}
+
+// Exec runs cmd in the pod's container and returns its output.
+// If the command fails, the output is returned along with the error.
+func (k *Kubernetes) Exec(ctx context.Context, pod client.ObjectKey, container string, cmd []string) (*ExecResult, error) {
+	cli, err := k.Clientset()
+	if err != nil {
+		return nil, err
+	}
+
+	req := cli.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   cmd,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	e, err := remotecommand.NewSPDYExecutor(k.RESTConfig(), http.MethodPost, req.URL())
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	err = e.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	r := &ExecResult{Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	if err != nil {
+		return r, fmt.Errorf("error executing %v in %s container %s: %w", cmd, pod, container, err)
+	}
+	return r, nil
+}
+
+// PortForward forwards a local port to the given port of target, a *corev1.Pod or a *corev1.Service.
+// For Services, the port is the Service's one and a ready Pod backing it is chosen.
+// It returns the local address and a function to stop forwarding.
+// Forwarding is stopped also when ctx is done.
+func (k *Kubernetes) PortForward(ctx context.Context, target client.Object, port int) (string, func(), error) {
+	pod, podPort, err := k.portForwardPod(ctx, target, port)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cli, err := k.Clientset()
+	if err != nil {
+		return "", nil, err
+	}
+	u := cli.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+
+	rt, upgrader, err := spdy.RoundTripperFor(k.RESTConfig())
+	if err != nil {
+		return "", nil, err
+	}
+	d := spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u)
+
+	stopCh, readyCh := make(chan struct{}), make(chan struct{})
+	fw, err := portforward.NewOnAddresses(d, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", podPort)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return "", nil, err
+	}
+
+	once := sync.Once{}
+	stop := func() { once.Do(func() { close(stopCh) }) }
+	errCh := make(chan error, 1)
+	go func() { errCh <- fw.ForwardPorts() }()
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-stopCh:
+		}
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		stop()
+		return "", nil, fmt.Errorf("error forwarding port %d of pod %s/%s: %w", podPort, pod.Namespace, pod.Name, err)
+	case <-ctx.Done():
+		stop()
+		return "", nil, ctx.Err()
+	}
+
+	pp, err := fw.GetPorts()
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(int(pp[0].Local))), stop, nil
+}
+
+// portForwardPod returns the pod and its port to forward to
+func (k *Kubernetes) portForwardPod(ctx context.Context, target client.Object, port int) (*corev1.Pod, int, error) {
+	switch t := target.(type) {
+	case *corev1.Pod:
+		return t, port, nil
+	case *corev1.Service:
+		s := corev1.Service{}
+		if err := k.Get(ctx, client.ObjectKeyFromObject(t), &s); err != nil {
+			return nil, 0, err
+		}
+
+		pod, err := k.readyPod(ctx, s.Namespace, s.Spec.Selector)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: service %s/%s", err, s.Namespace, s.Name)
+		}
+
+		for _, sp := range s.Spec.Ports {
+			if int(sp.Port) == port {
+				pp, err := targetPort(pod, sp.TargetPort, port)
+				return pod, pp, err
+			}
+		}
+		return nil, 0, fmt.Errorf("%w: %d in service %s/%s", ErrPortNotFound, port, s.Namespace, s.Name)
+	default:
+		return nil, 0, fmt.Errorf("%w: %T", ErrUnsupportedTarget, target)
+	}
+}
+
+// readyPod returns a ready pod matching the selector
+func (k *Kubernetes) readyPod(ctx context.Context, namespace string, selector map[string]string) (*corev1.Pod, error) {
+	pp := corev1.PodList{}
+	if err := k.List(ctx, &pp, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(selector)}); err != nil {
+		return nil, err
+	}
+
+	for _, p := range pp.Items {
+		if p.Status.Phase != corev1.PodRunning || p.DeletionTimestamp != nil {
+			continue
+		}
+		for _, c := range p.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				return &p, nil
+			}
+		}
+	}
+	return nil, ErrNoReadyPod
+}
+
+// targetPort resolves a Service's target port on the given pod
+func targetPort(pod *corev1.Pod, tp intstr.IntOrString, port int) (int, error) {
+	switch {
+	case tp.Type == intstr.Int && tp.IntVal == 0:
+		// target port defaults to the Service's port
+		return port, nil
+	case tp.Type == intstr.Int:
+		return int(tp.IntVal), nil
+	}
+
+	for _, c := range pod.Spec.Containers {
+		for _, cp := range c.Ports {
+			if cp.Name == tp.StrVal {
+				return int(cp.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: named port %s in pod %s/%s", ErrPortNotFound, tp.StrVal, pod.Namespace, pod.Name)
+}
+
+// NamespacedKubernetes' helpers work on the client's namespace
+
+func (k *NamespacedKubernetes) Logs(ctx context.Context, pod client.ObjectKey, container string, opts corev1.PodLogOptions) (io.ReadCloser, error) {
+	pod.Namespace = k.Namespace
+	return k.Kubernetes.Logs(ctx, pod, container, opts)
+}
+
+func (k *NamespacedKubernetes) WaitForLogLine(ctx context.Context, pod client.ObjectKey, container string, re *regexp.Regexp) (string, error) {
+	return waitForLogLine(ctx, k, pod, container, re)
+}
+
+func (k *NamespacedKubernetes) Exec(ctx context.Context, pod client.ObjectKey, container string, cmd []string) (*ExecResult, error) {
+	pod.Namespace = k.Namespace
+	return k.Kubernetes.Exec(ctx, pod, container, cmd)
+}
+
+func (k *NamespacedKubernetes) PortForward(ctx context.Context, target client.Object, port int) (string, func(), error) {
+	lt, ok := target.DeepCopyObject().(client.Object)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %T", ErrUnsupportedTarget, target)
+	}
+	lt.SetNamespace(k.Namespace)
+	return k.Kubernetes.PortForward(ctx, lt, port)
+}
+
+// MultiNamespacedKubernetes' helpers work on the client's namespaces, the default one if none is specified
+
+func (k *MultiNamespacedKubernetes) Logs(ctx context.Context, pod client.ObjectKey, container string, opts corev1.PodLogOptions) (io.ReadCloser, error) {
+	ns, err := k.scopeNamespace(pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	pod.Namespace = ns
+	return k.Kubernetes.Logs(ctx, pod, container, opts)
+}
+
+func (k *MultiNamespacedKubernetes) WaitForLogLine(ctx context.Context, pod client.ObjectKey, container string, re *regexp.Regexp) (string, error) {
+	return waitForLogLine(ctx, k, pod, container, re)
+}
+
+func (k *MultiNamespacedKubernetes) Exec(ctx context.Context, pod client.ObjectKey, container string, cmd []string) (*ExecResult, error) {
+	ns, err := k.scopeNamespace(pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	pod.Namespace = ns
+	return k.Kubernetes.Exec(ctx, pod, container, cmd)
+}
+
+func (k *MultiNamespacedKubernetes) PortForward(ctx context.Context, target client.Object, port int) (string, func(), error) {
+	ns, err := k.scopeNamespace(target.GetNamespace())
+	if err != nil {
+		return "", nil, err
+	}
+	lt, ok := target.DeepCopyObject().(client.Object)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %T", ErrUnsupportedTarget, target)
+	}
+	lt.SetNamespace(ns)
+	return k.Kubernetes.PortForward(ctx, lt, port)
+}
+
+func (k *MultiNamespacedKubernetes) scopeNamespace(namespace string) (string, error) {
+	nn, err := k.WithWatch.(*MultiNamespaceClient).targetNamespaces(namespace)
+	if err != nil {
+		return "", err
+	}
+	return nn[0], nil
+}
@@ -0,0 +1,391 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeLogsServer serves the logs of the pods in namespace test,
+// the logs are complete from the second request on
+type fakeLogsServer struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (s *fakeLogsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/test/pods/") || !strings.HasSuffix(r.URL.Path, "/log") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.requests++
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(w, "starting\n")
+	if s.requests > 1 {
+		_, _ = io.WriteString(w, "container="+r.URL.Query().Get("container")+"\nlistening on :8080\n")
+	}
+}
+
+func Test_Logs(t *testing.T) {
+	s := &fakeLogsServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	k, err := kube.NewNamespaced(&rest.Config{Host: srv.URL}, client.Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("logs are read in the client's namespace", func(t *testing.T) {
+		r, err := k.Logs(context.Background(), client.ObjectKey{Namespace: "other", Name: "p"}, "c", corev1.PodLogOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		l, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(l), "starting\n") {
+			t.Errorf("expected logs to start with 'starting', got %q", l)
+		}
+	})
+
+	t.Run("waits for a log line", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		l, err := k.WaitForLogLine(ctx, client.ObjectKey{Name: "p"}, "c", regexp.MustCompile(`^listening on :\d+$`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l != "listening on :8080" {
+			t.Errorf("expected line 'listening on :8080', got %q", l)
+		}
+	})
+
+	t.Run("missing log line fails on timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := k.WaitForLogLine(ctx, client.ObjectKey{Name: "p"}, "c", regexp.MustCompile(`ready`))
+		if err == nil || errors.Is(err, context.Canceled) {
+			t.Errorf("expected error waiting for log line, got %v", err)
+		}
+	})
+}
+
+// fakePodsServer serves the Service web, backed by the pods labeled app=web of which only ready is ready,
+// and the Service idle, backed by no pod. Exec and port-forward requests are recorded and rejected.
+type fakePodsServer struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (s *fakePodsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case "/apis":
+		_ = e.Encode(metav1.APIGroupList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"}})
+		return
+	case "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Namespaced: true, Kind: "Pod", Verbs: []string{"get", "list"}},
+				{Name: "services", Namespaced: true, Kind: "Service", Verbs: []string{"get", "list"}},
+			},
+		})
+		return
+	}
+
+	p, _ := strings.CutPrefix(r.URL.Path, "/api/v1/namespaces/")
+	ns, rest, _ := strings.Cut(p, "/")
+	switch {
+	case r.Method == http.MethodPost:
+		s.paths = append(s.paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+		_ = e.Encode(kerrors.NewForbidden(schema.GroupResource{Resource: "pods"}, rest, errors.New("denied")).Status())
+	case rest == "services/web" || rest == "services/idle":
+		s.paths = append(s.paths, r.Method+" "+r.URL.Path)
+		_ = e.Encode(fakeService(ns, strings.TrimPrefix(rest, "services/")))
+	case rest == "pods":
+		pp := corev1.PodList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"}}
+		if r.URL.Query().Get("labelSelector") == "app=web" {
+			pp.Items = fakePods(ns)
+		}
+		_ = e.Encode(pp)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_ = e.Encode(kerrors.NewNotFound(schema.GroupResource{Resource: "services"}, rest).Status())
+	}
+}
+
+func fakeService(namespace, name string) corev1.Service {
+	return corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports: []corev1.ServicePort{
+				{Name: "named", Port: 80, TargetPort: intstr.FromString("http")},
+				{Name: "number", Port: 81, TargetPort: intstr.FromInt32(9090)},
+				{Name: "default", Port: 82},
+				{Name: "missing", Port: 83, TargetPort: intstr.FromString("metrics")},
+			},
+		},
+	}
+}
+
+func fakePods(namespace string) []corev1.Pod {
+	pod := func(name string, phase corev1.PodPhase, ready corev1.ConditionStatus) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "web"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:  "web",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			}}},
+			Status: corev1.PodStatus{
+				Phase:      phase,
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+			},
+		}
+	}
+
+	terminating := pod("terminating", corev1.PodRunning, corev1.ConditionTrue)
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	return []corev1.Pod{
+		pod("pending", corev1.PodPending, corev1.ConditionFalse),
+		pod("starting", corev1.PodRunning, corev1.ConditionFalse),
+		terminating,
+		pod("ready", corev1.PodRunning, corev1.ConditionTrue),
+	}
+}
+
+func (s *fakePodsServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pp := s.paths
+	s.paths = nil
+	return pp
+}
+
+func Test_PortForwardPod(t *testing.T) {
+	srv := httptest.NewServer(&fakePodsServer{})
+	defer srv.Close()
+
+	k, err := kube.New(&rest.Config{Host: srv.URL}, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := func(name string) client.Object {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"}}
+	}
+
+	tt := map[string]struct {
+		target  client.Object
+		port    int
+		pod     string
+		podPort int
+		err     error
+	}{
+		"pod port is forwarded as is": {
+			target:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "test"}},
+			port:    80,
+			pod:     "p",
+			podPort: 80,
+		},
+		"named target port":                    {target: svc("web"), port: 80, pod: "ready", podPort: 8080},
+		"numeric target port":                  {target: svc("web"), port: 81, pod: "ready", podPort: 9090},
+		"target port defaults to service port": {target: svc("web"), port: 82, pod: "ready", podPort: 82},
+		"named target port missing in pod":     {target: svc("web"), port: 83, err: kube.ErrPortNotFound},
+		"port missing in service":              {target: svc("web"), port: 84, err: kube.ErrPortNotFound},
+		"no ready pod":                         {target: svc("idle"), port: 80, err: kube.ErrNoReadyPod},
+		"unsupported target": {
+			target: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "test"}},
+			port:   80,
+			err:    kube.ErrUnsupportedTarget,
+		},
+	}
+
+	for n, tc := range tt {
+		t.Run(n, func(t *testing.T) {
+			pod, port, err := k.PortForwardPod(context.Background(), tc.target, tc.port)
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pod.Name != tc.pod || port != tc.podPort {
+				t.Errorf("expected pod %s port %d, got pod %s port %d", tc.pod, tc.podPort, pod.Name, port)
+			}
+		})
+	}
+
+	t.Run("missing service", func(t *testing.T) {
+		_, _, err := k.PortForwardPod(context.Background(), svc("missing"), 80)
+		if !kerrors.IsNotFound(err) {
+			t.Errorf("expected NotFound, got %v", err)
+		}
+	})
+}
+
+func Test_ExecAndPortForward_Namespaces(t *testing.T) {
+	s := &fakePodsServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	cfg := &rest.Config{Host: srv.URL}
+	nk, err := kube.NewNamespaced(cfg, client.Options{}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mk, err := kube.NewMultiNamespaced(cfg, client.Options{}, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: namespace}}
+	}
+	web := func(namespace string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace}}
+	}
+	exec := func(k kube.Client, namespace string) error {
+		_, err := k.Exec(context.Background(), client.ObjectKey{Namespace: namespace, Name: "p"}, "c", []string{"true"})
+		return err
+	}
+	forward := func(target client.Object) func(k kube.Client, namespace string) error {
+		return func(k kube.Client, _ string) error {
+			_, stop, err := k.PortForward(context.Background(), target, 80)
+			if stop != nil {
+				stop()
+			}
+			return err
+		}
+	}
+
+	tt := map[string]struct {
+		client    kube.Client
+		namespace string
+		call      func(k kube.Client, namespace string) error
+		requests  []string
+		err       error
+	}{
+		"namespaced exec": {
+			client:    nk,
+			namespace: "other",
+			call:      exec,
+			requests:  []string{"POST /api/v1/namespaces/test/pods/p/exec"},
+		},
+		"namespaced pod port-forward": {
+			client:   nk,
+			call:     forward(pod("other")),
+			requests: []string{"POST /api/v1/namespaces/test/pods/p/portforward"},
+		},
+		"namespaced service port-forward": {
+			client: nk,
+			call:   forward(web("other")),
+			requests: []string{
+				"GET /api/v1/namespaces/test/services/web",
+				"POST /api/v1/namespaces/test/pods/ready/portforward",
+			},
+		},
+		"multi-namespaced exec in default namespace": {
+			client:   mk,
+			call:     exec,
+			requests: []string{"POST /api/v1/namespaces/a/pods/p/exec"},
+		},
+		"multi-namespaced exec": {
+			client:    mk,
+			namespace: "b",
+			call:      exec,
+			requests:  []string{"POST /api/v1/namespaces/b/pods/p/exec"},
+		},
+		"multi-namespaced exec in other namespace": {
+			client:    mk,
+			namespace: "other",
+			call:      exec,
+			err:       kube.ErrNamespaceNotAllowed,
+		},
+		"multi-namespaced pod port-forward in default namespace": {
+			client:   mk,
+			call:     forward(pod("")),
+			requests: []string{"POST /api/v1/namespaces/a/pods/p/portforward"},
+		},
+		"multi-namespaced service port-forward": {
+			client: mk,
+			call:   forward(web("b")),
+			requests: []string{
+				"GET /api/v1/namespaces/b/services/web",
+				"POST /api/v1/namespaces/b/pods/ready/portforward",
+			},
+		},
+		"multi-namespaced port-forward in other namespace": {
+			client: mk,
+			call:   forward(web("other")),
+			err:    kube.ErrNamespaceNotAllowed,
+		},
+	}
+
+	for n, tc := range tt {
+		t.Run(n, func(t *testing.T) {
+			s.requests()
+
+			err := tc.call(tc.client, tc.namespace)
+			switch {
+			case tc.err != nil && !errors.Is(err, tc.err):
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			case tc.err == nil && err == nil:
+				t.Fatal("expected the server to reject the request")
+			}
+
+			rr := s.requests()
+			if len(rr) != len(tc.requests) {
+				t.Fatalf("expected requests %v, got %v", tc.requests, rr)
+			}
+			for i := range rr {
+				if rr[i] != tc.requests[i] {
+					t.Errorf("expected request %s, got %s", tc.requests[i], rr[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package kube
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// value replacing redacted fields
+const redacted string = "REDACTED"
+
+// APICall is the record of a request sent to the API server
+type APICall struct {
+	Time time.Time `json:"time"`
+	RequestInfo
+	// Kind of the object in the request or in the response, if any
+	Kind       string `json:"kind,omitempty"`
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	// Latency in nanoseconds, until the response headers are received
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+	// Request and response bodies are recorded only if enabled and JSON encoded.
+	// The content of Secrets and of minted tokens is redacted.
+	RequestBody  json.RawMessage `json:"requestBody,omitempty"`
+	ResponseBody json.RawMessage `json:"responseBody,omitempty"`
+}
+
+type RecorderOptions struct {
+	// Record request and response bodies, watch streams excluded
+	RecordBodies bool
+}
+
+// Recorder records the requests sent through the clients it wraps as JSON lines
+type Recorder struct {
+	opts RecorderOptions
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewRecorder(w io.Writer, opts RecorderOptions) *Recorder {
+	return &Recorder{w: w, opts: opts}
+}
+
+// NewFileRecorder returns a recorder appending to the file at path, creating it if needed
+func NewFileRecorder(path string, opts RecorderOptions) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecorder(f, opts), nil
+}
+
+// Close closes the underlying writer, if closable
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WrapConfig returns a copy of cfg whose requests are recorded
+func (r *Recorder) WrapConfig(cfg *rest.Config) *rest.Config {
+	rc := rest.CopyConfig(cfg)
+	rc.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &recordingRoundTripper{recorder: r, next: rt}
+	})
+	return rc
+}
+
+// WrapClient rebuilds the client k on top of a recorded copy of its rest.Config,
+// preserving its namespace scope
+func (r *Recorder) WrapClient(k Client) (Client, error) {
+	return WithRESTConfig(k, r.WrapConfig(k.RESTConfig()))
+}
+
+func (r *Recorder) record(c APICall) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(b, '\n'))
+	return err
+}
+
+type recordingRoundTripper struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := APICall{
+		Time:        time.Now(),
+		RequestInfo: ParseRequestInfo(req),
+		Method:      req.Method,
+		URL:         req.URL.RequestURI(),
+	}
+
+	// read the request body and restore it for the next round tripper
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	c.Latency = time.Since(c.Time)
+	if err != nil {
+		c.Error = err.Error()
+	}
+
+	// watch streams are not buffered
+	var respBody []byte
+	if resp != nil {
+		c.StatusCode = resp.StatusCode
+		if c.Verb != "watch" && isJSON(resp.Header) {
+			b, rerr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if rerr != nil {
+				// hand the read error over to the caller, after the bytes read so far
+				c.Error = rerr.Error()
+				resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b), errReader{rerr}))
+			} else {
+				resp.Body = io.NopCloser(bytes.NewReader(b))
+				respBody = b
+			}
+		}
+	}
+
+	c.Kind = objectKind(respBody)
+	if k := objectKind(reqBody); k != "" && (c.Kind == "" || c.Kind == "Status") {
+		c.Kind = k
+	}
+	if t.recorder.opts.RecordBodies {
+		if isJSON(req.Header) {
+			c.RequestBody = redact(c.RequestInfo, reqBody)
+		}
+		c.ResponseBody = redact(c.RequestInfo, respBody)
+	}
+
+	// recording errors do not fail the API call
+	if rerr := t.recorder.record(c); rerr != nil {
+		log.Printf("error recording API call %s %s: %v", c.Method, c.URL, rerr)
+	}
+	return resp, err
+}
+
+// errReader always fails reading with err
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func isJSON(h http.Header) bool {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
+
+func objectKind(body []byte) string {
+	o := struct {
+		Kind string `json:"kind"`
+	}{}
+	if len(body) == 0 || json.Unmarshal(body, &o) != nil {
+		return ""
+	}
+	return o.Kind
+}
+
+// redact replaces the content of Secrets and minted tokens in the body.
+// Bodies that are not valid JSON are dropped.
+func redact(info RequestInfo, body []byte) json.RawMessage {
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+
+	if !isSensitive(info) {
+		return body
+	}
+
+	o := map[string]interface{}{}
+	if err := json.Unmarshal(body, &o); err != nil {
+		// e.g. a JSON patch
+		b, _ := json.Marshal(redacted)
+		return b
+	}
+
+	if isToken(info) {
+		if s, ok := o["status"].(map[string]interface{}); ok && s["token"] != nil {
+			s["token"] = redacted
+		}
+	}
+	if isSecret(info) {
+		redactSecret(o)
+		if ii, ok := o["items"].([]interface{}); ok {
+			for _, i := range ii {
+				if s, ok := i.(map[string]interface{}); ok {
+					redactSecret(s)
+				}
+			}
+		}
+	}
+
+	b, err := json.Marshal(o)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// isSensitive returns whether the request's bodies contain Secrets' data or minted tokens
+func isSensitive(info RequestInfo) bool {
+	return isSecret(info) || isToken(info)
+}
+
+func isSecret(info RequestInfo) bool {
+	return info.Resource == "secrets" && info.Group == ""
+}
+
+func isToken(info RequestInfo) bool {
+	return info.Subresource == "token"
+}
+
+func redactSecret(o map[string]interface{}) {
+	for _, f := range []string{"data", "stringData"} {
+		if d, ok := o[f].(map[string]interface{}); ok {
+			for k := range d {
+				d[k] = redacted
+			}
+		}
+	}
+
+	// the last applied configuration contains the Secret's data
+	if m, ok := o["metadata"].(map[string]interface{}); ok {
+		if aa, ok := m["annotations"].(map[string]interface{}); ok {
+			if _, ok := aa[lastAppliedConfigurationAnnotation]; ok {
+				aa[lastAppliedConfigurationAnnotation] = redacted
+			}
+		}
+	}
+}
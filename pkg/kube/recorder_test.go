@@ -0,0 +1,163 @@
+package kube_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_ParseRequestInfo(t *testing.T) {
+	tt := []struct {
+		method   string
+		url      string
+		expected kube.RequestInfo
+	}{
+		{"GET", "/api/v1/namespaces/test/pods", kube.RequestInfo{Verb: "list", Version: "v1", Resource: "pods", Namespace: "test"}},
+		{"GET", "/api/v1/namespaces/test/pods?watch=true", kube.RequestInfo{Verb: "watch", Version: "v1", Resource: "pods", Namespace: "test"}},
+		{"GET", "/api/v1/namespaces/test/pods/p/log", kube.RequestInfo{Verb: "get", Version: "v1", Resource: "pods", Namespace: "test", Name: "p", Subresource: "log"}},
+		{"PUT", "/api/v1/namespaces/test/status", kube.RequestInfo{Verb: "update", Version: "v1", Resource: "namespaces", Namespace: "test", Name: "test", Subresource: "status"}},
+		{"PATCH", "/apis/apps/v1/namespaces/test/deployments/d", kube.RequestInfo{Verb: "patch", Group: "apps", Version: "v1", Resource: "deployments", Namespace: "test", Name: "d"}},
+		{"DELETE", "/apis/apps/v1/namespaces/test/deployments", kube.RequestInfo{Verb: "deletecollection", Group: "apps", Version: "v1", Resource: "deployments", Namespace: "test"}},
+		{"POST", "/apis/apiextensions.k8s.io/v1/customresourcedefinitions", kube.RequestInfo{Verb: "create", Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}},
+		{"GET", "/livez", kube.RequestInfo{Verb: "get", Path: "/livez"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.method+" "+tc.url, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, nil)
+			if i := kube.ParseRequestInfo(r); i != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, i)
+			}
+		})
+	}
+}
+
+// echoServer echoes back request bodies, or returns a Secret
+type echoServer struct{}
+
+func (echoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	b, _ := io.ReadAll(r.Body)
+	if len(b) == 0 {
+		b, _ = json.Marshal(corev1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: "s", Namespace: "test"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		})
+	}
+	w.WriteHeader(http.StatusCreated)
+	_, _ = w.Write(b)
+}
+
+func Test_Recorder(t *testing.T) {
+	srv := httptest.NewServer(echoServer{})
+	defer srv.Close()
+
+	record := func(t *testing.T, opts kube.RecorderOptions) []kube.APICall {
+		t.Helper()
+
+		buf := bytes.Buffer{}
+		r := kube.NewRecorder(&buf, opts)
+		cli, err := kubernetes.NewForConfig(r.WrapConfig(&rest.Config{Host: srv.URL}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "s"},
+			StringData: map[string]string{"password": "secret"},
+		}
+		if _, err := cli.CoreV1().Secrets("test").Create(ctx, s, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cli.CoreV1().Secrets("test").Get(ctx, "s", metav1.GetOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		cc := []kube.APICall{}
+		sc := bufio.NewScanner(&buf)
+		for sc.Scan() {
+			c := kube.APICall{}
+			if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+				t.Fatal(err)
+			}
+			cc = append(cc, c)
+		}
+		if len(cc) != 2 {
+			t.Fatalf("expected 2 API calls to be recorded, got %d: %s", len(cc), buf.String())
+		}
+		return cc
+	}
+
+	t.Run("calls are recorded", func(t *testing.T) {
+		cc := record(t, kube.RecorderOptions{})
+
+		for i, v := range []string{"create", "get"} {
+			c := cc[i]
+			if c.Verb != v || c.Resource != "secrets" || c.Namespace != "test" || c.Kind != "Secret" || c.StatusCode != http.StatusCreated {
+				t.Errorf("unexpected API call recorded for %s: %+v", v, c)
+			}
+			if c.RequestBody != nil || c.ResponseBody != nil {
+				t.Errorf("expected bodies not to be recorded, got %+v", c)
+			}
+		}
+		if cc[1].Name != "s" {
+			t.Errorf("expected get of Secret s, got %s", cc[1].Name)
+		}
+	})
+
+	t.Run("secrets are redacted", func(t *testing.T) {
+		cc := record(t, kube.RecorderOptions{RecordBodies: true})
+
+		for _, c := range cc {
+			for _, b := range []json.RawMessage{c.RequestBody, c.ResponseBody} {
+				if len(b) == 0 && c.Verb == "get" {
+					continue
+				}
+				if strings.Contains(string(b), "secret") || strings.Contains(string(b), "c2VjcmV0") {
+					t.Errorf("expected Secret's data to be redacted, got %s", b)
+				}
+				if !strings.Contains(string(b), "REDACTED") {
+					t.Errorf("expected Secret's data to be replaced, got %s", b)
+				}
+			}
+		}
+	})
+
+	t.Run("recording errors do not fail calls", func(t *testing.T) {
+		r := kube.NewRecorder(failingWriter{}, kube.RecorderOptions{})
+		cli, err := kubernetes.NewForConfig(r.WrapConfig(&rest.Config{Host: srv.URL}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := cli.CoreV1().Secrets("test").Get(context.Background(), "s", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected call to succeed, got %v", err)
+		}
+		if s.Name != "s" {
+			t.Errorf("expected Secret s, got %s", s.Name)
+		}
+	})
+}
+
+// failingWriter fails all writes
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
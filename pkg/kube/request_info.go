@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"net/http"
+	"strings"
+)
+
+// subresources of the namespaces resource, that are not namespaced resources
+var namespaceSubresources = map[string]bool{"status": true, "finalize": true}
+
+// RequestInfo describes the Kubernetes API request an HTTP request maps to
+type RequestInfo struct {
+	// Verb is the Kubernetes verb: get, list, watch, create, update, patch, delete or deletecollection.
+	// For non-resource requests, e.g. /livez, it is the lowercase HTTP method.
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	// Path is set for non-resource requests only
+	Path string `json:"path,omitempty"`
+}
+
+// IsResourceRequest returns true if the request targets a resource
+func (i RequestInfo) IsResourceRequest() bool {
+	return i.Resource != ""
+}
+
+// ParseRequestInfo maps an HTTP request to the Kubernetes API request it represents.
+// Requests not targeting resources, e.g. discovery ones, are returned with the Path only.
+func ParseRequestInfo(r *http.Request) RequestInfo {
+	i := RequestInfo{Verb: strings.ToLower(r.Method)}
+
+	pp := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(pp) >= 3 && pp[0] == "api":
+		i.Version, pp = pp[1], pp[2:]
+	case len(pp) >= 4 && pp[0] == "apis":
+		i.Group, i.Version, pp = pp[1], pp[2], pp[3:]
+	default:
+		i.Path = r.URL.Path
+		return i
+	}
+
+	// legacy watch paths, e.g. /api/v1/watch/namespaces/<namespace>/pods
+	if pp[0] == "watch" && len(pp) > 1 {
+		pp = pp[1:]
+	}
+	// namespaces/<namespace>/<resource>, but not the namespace's subresources
+	if len(pp) >= 3 && pp[0] == "namespaces" && !namespaceSubresources[pp[2]] {
+		i.Namespace, pp = pp[1], pp[2:]
+	}
+
+	i.Resource = pp[0]
+	if len(pp) > 1 {
+		i.Name = pp[1]
+	}
+	if len(pp) > 2 {
+		i.Subresource = strings.Join(pp[2:], "/")
+	}
+	if i.Resource == "namespaces" && i.Namespace == "" {
+		i.Namespace = i.Name
+	}
+
+	i.Verb = verb(r, i)
+	return i
+}
+
+func verb(r *http.Request, i RequestInfo) string {
+	switch r.Method {
+	case http.MethodGet:
+		switch {
+		case r.URL.Query().Get("watch") == "true" || strings.Contains(r.URL.Path, "/watch/"):
+			return "watch"
+		case i.Name == "":
+			return "list"
+		default:
+			return "get"
+		}
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		if i.Name == "" {
+			return "deletecollection"
+		}
+		return "delete"
+	default:
+		return strings.ToLower(r.Method)
+	}
+}
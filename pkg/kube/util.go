@@ -122,7 +122,10 @@ func SerializeKubeconfig(cfg *rest.Config, namespace *string) ([]byte, error) {
 	cl := map[string]*clientcmdapi.Cluster{
 		"default-cluster": {
 			Server:                   cfg.Host,
+			CertificateAuthority:     cfg.CAFile,
 			CertificateAuthorityData: cfg.CAData,
+			InsecureSkipTLSVerify:    cfg.Insecure,
+			TLSServerName:            cfg.ServerName,
 		},
 	}
 
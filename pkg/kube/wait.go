@@ -0,0 +1,129 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+var ErrWaitTimeout error = fmt.Errorf("error timed out waiting for resource")
+
+// Predicate checks the observed state of a resource.
+// u is nil if the resource does not exist.
+type Predicate func(u *unstructured.Unstructured) (bool, error)
+
+// WaitFor waits until the predicate is satisfied by the given resource, or the context is done.
+// It returns the last observed state of the resource, nil if it does not exist.
+// On timeout the returned error wraps ErrWaitTimeout.
+func (k *Kubernetes) WaitFor(ctx context.Context, u unstructured.Unstructured, p Predicate) (*unstructured.Unstructured, error) {
+	if err := k.checkMapping(u); err != nil {
+		return nil, err
+	}
+
+	var last *unstructured.Unstructured
+	w := &resourceWatcher{k: k, u: u, notifyMissing: true, check: func(e watch.Event) (bool, error) {
+		last = nil
+		if o, ok := e.Object.(*unstructured.Unstructured); ok && e.Type != watch.Deleted {
+			last = o
+		}
+		return p(last)
+	}}
+
+	done, err := w.list(ctx)
+	if err == nil && !done {
+		err = w.run(ctx)
+	}
+	if err != nil && ctx.Err() != nil {
+		return last, fmt.Errorf("%w: %s %s/%s: %w", ErrWaitTimeout, u.GetKind(), u.GetNamespace(), u.GetName(), err)
+	}
+	return last, err
+}
+
+// WaitFor waits until the predicate is satisfied by the given resource in the client's namespace
+func (k *NamespacedKubernetes) WaitFor(ctx context.Context, u unstructured.Unstructured, p Predicate) (*unstructured.Unstructured, error) {
+	lu := u.DeepCopy()
+	lu.SetNamespace(k.Namespace)
+	return k.Kubernetes.WaitFor(ctx, *lu, p)
+}
+
+// HasCondition is satisfied when the resource has the condition of the given type with the given status
+func HasCondition(conditionType string, status metav1.ConditionStatus) Predicate {
+	return func(u *unstructured.Unstructured) (bool, error) {
+		return u != nil && conditionStatus(*u, conditionType) == string(status), nil
+	}
+}
+
+// FieldEquals is satisfied when the JSONPath expression evaluated on the resource equals value.
+// The expression can be given with or without the surrounding braces, e.g. `.status.phase`.
+func FieldEquals(path, value string) Predicate {
+	return func(u *unstructured.Unstructured) (bool, error) {
+		if u == nil {
+			return false, nil
+		}
+
+		v, err := EvaluateJSONPath(*u, path)
+		if err != nil {
+			return false, err
+		}
+		return v == value, nil
+	}
+}
+
+// ObservedGenerationCurrent is satisfied when the resource's status.observedGeneration
+// is equal to or greater than metadata.generation, i.e. the controller processed its latest spec
+func ObservedGenerationCurrent(u *unstructured.Unstructured) (bool, error) {
+	if u == nil {
+		return false, nil
+	}
+
+	og, ok, err := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+	if err != nil || !ok {
+		return false, err
+	}
+	return og >= u.GetGeneration(), nil
+}
+
+// Deleted is satisfied when the resource does not exist
+func Deleted(u *unstructured.Unstructured) (bool, error) {
+	return u == nil, nil
+}
+
+// EvaluateJSONPath evaluates the JSONPath expression on the resource.
+// Missing fields evaluate to an empty string.
+func EvaluateJSONPath(u unstructured.Unstructured, path string) (string, error) {
+	if !strings.HasPrefix(path, "{") {
+		path = fmt.Sprintf("{%s}", path)
+	}
+
+	jp := jsonpath.New("field").AllowMissingKeys(true)
+	if err := jp.Parse(path); err != nil {
+		return "", fmt.Errorf("error parsing JSONPath %s: %w", path, err)
+	}
+
+	b := bytes.Buffer{}
+	if err := jp.Execute(&b, u.Object); err != nil {
+		return "", fmt.Errorf("error evaluating JSONPath %s: %w", path, err)
+	}
+	return b.String(), nil
+}
+
+// conditionStatus returns the status of the condition of the given type, or an empty string if not found
+func conditionStatus(u unstructured.Unstructured, conditionType string) string {
+	cc, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range cc {
+		cm, ok := c.(map[string]interface{})
+		if !ok || cm["type"] != conditionType {
+			continue
+		}
+
+		s, _ := cm["status"].(string)
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package kube_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+func Test_Predicates(t *testing.T) {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "test", "generation": int64(3)},
+		"status": map[string]interface{}{
+			"phase":              "Provisioned",
+			"observedGeneration": int64(2),
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+				map[string]interface{}{"type": "Degraded", "status": "False"},
+			},
+		},
+	}}
+
+	tt := map[string]struct {
+		predicate kube.Predicate
+		object    *unstructured.Unstructured
+		expected  bool
+	}{
+		"condition with matching status":   {kube.HasCondition("Ready", metav1.ConditionTrue), u, true},
+		"condition with other status":      {kube.HasCondition("Degraded", metav1.ConditionTrue), u, false},
+		"missing condition":                {kube.HasCondition("Available", metav1.ConditionTrue), u, false},
+		"field equals":                     {kube.FieldEquals(".status.phase", "Provisioned"), u, true},
+		"field equals with braces":         {kube.FieldEquals("{.status.phase}", "Provisioned"), u, true},
+		"field differs":                    {kube.FieldEquals(".status.phase", "Pending"), u, false},
+		"missing field":                    {kube.FieldEquals(".status.missing", "Pending"), u, false},
+		"observed generation outdated":     {kube.ObservedGenerationCurrent, u, false},
+		"existing resource is not deleted": {kube.Deleted, u, false},
+		"missing resource is deleted":      {kube.Deleted, nil, true},
+		"missing resource has no fields":   {kube.FieldEquals(".status.phase", ""), nil, false},
+	}
+
+	for n, tc := range tt {
+		tc := tc
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			ok, err := tc.predicate(tc.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, ok)
+			}
+		})
+	}
+}
+
+func Test_WaitFor(t *testing.T) {
+	srv := httptest.NewServer(&fakeWatchServer{})
+	defer srv.Close()
+
+	k, err := kube.New(&rest.Config{Host: srv.URL}, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("test")
+	u.SetName("watched")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	o, err := k.WaitFor(ctx, u, kube.FieldEquals(".data.phase", "Ready"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o == nil || o.GetResourceVersion() != "5" {
+		t.Errorf("expected the last observed object to be returned, got %v", o)
+	}
+}
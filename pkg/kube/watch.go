@@ -0,0 +1,219 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// time to wait before reconnecting a closed watch
+const watchReconnectDelay = 1 * time.Second
+
+// WatchResourceUnstructured watches the given resource starting from the given resourceVersion.
+// If resourceVersion is empty, the watch starts from the most recent one.
+func (k *Kubernetes) WatchResourceUnstructured(ctx context.Context, u unstructured.Unstructured, resourceVersion string) (watch.Interface, error) {
+	return k.Watch(ctx, resourceList(u),
+		client.InNamespace(u.GetNamespace()),
+		&client.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", u.GetName()),
+			Raw:           &metav1.ListOptions{ResourceVersion: resourceVersion, AllowWatchBookmarks: true},
+		})
+}
+
+// WatchForEventOnResourceUnstructured calls check on each event of the given resource until it returns true or an error.
+//
+// The resource is listed before the function returns, and its current state is passed to check
+// as an Added event, so states reached before the call are not missed.
+// The watch then starts from the listed resourceVersion and reconnects automatically
+// if it is closed by the server or it expires, in such case the resource is listed again.
+// A Deleted event is synthesized if the resource disappeared meanwhile.
+//
+// The returned channel is closed when check returns true, or after the error is sent.
+func (k *Kubernetes) WatchForEventOnResourceUnstructured(ctx context.Context, u unstructured.Unstructured, check func(e watch.Event) (bool, error)) (chan error, error) {
+	if err := k.checkMapping(u); err != nil {
+		return nil, err
+	}
+
+	w := &resourceWatcher{k: k, u: u, check: check}
+	done, err := w.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	we := make(chan error, 1)
+	if done {
+		close(we)
+		return we, nil
+	}
+
+	go func() {
+		defer close(we)
+		if err := w.run(ctx); err != nil {
+			we <- err
+		}
+	}()
+	return we, nil
+}
+
+// checkMapping checks the resource's kind is known, refreshing discovery information if needed
+func (k *Kubernetes) checkMapping(u unstructured.Unstructured) error {
+	return k.retryOnNoKindMatch(func() error {
+		_, err := k.mapper.RESTMapping(u.GroupVersionKind().GroupKind(), u.GroupVersionKind().Version)
+		return err
+	})
+}
+
+// resourceWatcher watches a single resource, reconnecting when needed
+type resourceWatcher struct {
+	k     *Kubernetes
+	u     unstructured.Unstructured
+	check func(e watch.Event) (bool, error)
+	// if true, a Deleted event is sent also when the resource does not exist at the first list
+	notifyMissing bool
+
+	// resourceVersion to resume the watch from, empty if the resource has to be listed again
+	resourceVersion string
+	// last observed state of the resource, nil if it does not exist
+	last *unstructured.Unstructured
+}
+
+// list retrieves the current state of the resource and checks it
+func (w *resourceWatcher) list(ctx context.Context) (bool, error) {
+	ll := resourceList(w.u)
+	if err := w.k.List(ctx, ll,
+		client.InNamespace(w.u.GetNamespace()),
+		client.MatchingFieldsSelector{Selector: fields.OneTermEqualSelector("metadata.name", w.u.GetName())},
+	); err != nil {
+		return false, err
+	}
+	w.resourceVersion = ll.GetResourceVersion()
+
+	switch {
+	case len(ll.Items) > 0:
+		et := watch.Added
+		if w.last != nil {
+			et = watch.Modified
+		}
+		w.last = &ll.Items[0]
+		return w.check(watch.Event{Type: et, Object: w.last})
+	case w.last != nil:
+		// resource deleted while not watching
+		e := watch.Event{Type: watch.Deleted, Object: w.last}
+		w.last = nil
+		return w.check(e)
+	case w.notifyMissing:
+		w.notifyMissing = false
+		return w.check(watch.Event{Type: watch.Deleted, Object: w.u.DeepCopy()})
+	default:
+		return false, nil
+	}
+}
+
+// run watches the resource until check returns true or an error, or the context is done
+func (w *resourceWatcher) run(ctx context.Context) error {
+	for {
+		if w.resourceVersion == "" {
+			done, err := w.list(ctx)
+			if err != nil || done {
+				return err
+			}
+		}
+
+		done, err := w.watch(ctx)
+		if err != nil || done {
+			return err
+		}
+
+		// expired watches are listed again right away
+		if w.resourceVersion == "" {
+			continue
+		}
+
+		// watch closed, reconnect
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(watchReconnectDelay):
+		}
+	}
+}
+
+// watch consumes the events of a single watch, it returns false with no error if the watch has to be restarted
+func (w *resourceWatcher) watch(ctx context.Context) (bool, error) {
+	wi, err := w.k.WatchResourceUnstructured(ctx, w.u, w.resourceVersion)
+	switch {
+	case isExpired(err):
+		w.resourceVersion = ""
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	defer wi.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case e, ok := <-wi.ResultChan():
+			if !ok {
+				log.Printf("watch on %s %s/%s closed, reconnecting", w.u.GetKind(), w.u.GetNamespace(), w.u.GetName())
+				return false, nil
+			}
+
+			switch e.Type {
+			case watch.Error:
+				err := kerrors.FromObject(e.Object)
+				if isExpired(err) {
+					log.Printf("watch on %s %s/%s expired, listing again", w.u.GetKind(), w.u.GetNamespace(), w.u.GetName())
+					w.resourceVersion = ""
+					return false, nil
+				}
+				return false, fmt.Errorf("error watching %s %s/%s: %w", w.u.GetKind(), w.u.GetNamespace(), w.u.GetName(), err)
+			case watch.Bookmark:
+				w.updateResourceVersion(e)
+				continue
+			}
+
+			w.updateResourceVersion(e)
+			if u, ok := e.Object.(*unstructured.Unstructured); ok {
+				w.last = u
+				if e.Type == watch.Deleted {
+					w.last = nil
+				}
+			}
+
+			if done, err := w.check(e); err != nil || done {
+				return done, err
+			}
+		}
+	}
+}
+
+func (w *resourceWatcher) updateResourceVersion(e watch.Event) {
+	if o, err := meta.Accessor(e.Object); err == nil && o.GetResourceVersion() != "" {
+		w.resourceVersion = o.GetResourceVersion()
+	}
+}
+
+// isExpired checks if the error is returned because the requested resourceVersion is too old
+func isExpired(err error) bool {
+	return kerrors.IsResourceExpired(err) || kerrors.IsGone(err)
+}
+
+// resourceList returns an empty list for the resource's kind
+func resourceList(u unstructured.Unstructured) *unstructured.UnstructuredList {
+	ll := &unstructured.UnstructuredList{}
+	gvk := u.GroupVersionKind()
+	gvk.Kind = gvk.Kind + "List"
+	ll.SetGroupVersionKind(gvk)
+	return ll
+}
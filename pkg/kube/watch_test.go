@@ -0,0 +1,142 @@
+package kube_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+// fakeWatchServer serves a single ConfigMap. The first watch is closed
+// after one event, the following ones expire right away.
+type fakeWatchServer struct {
+	mu      sync.Mutex
+	lists   int
+	watches []string
+}
+
+func (s *fakeWatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	e := json.NewEncoder(w)
+	switch r.URL.Path {
+	case "/api":
+		_ = e.Encode(metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+	case "/apis":
+		_ = e.Encode(metav1.APIGroupList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "APIGroupList"}})
+	case "/api/v1":
+		_ = e.Encode(metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{APIVersion: "v1", Kind: "APIResourceList"},
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: []string{"get", "list", "watch"}},
+			},
+		})
+	case "/api/v1/namespaces/test/configmaps":
+		if r.URL.Query().Get("watch") != "true" {
+			s.lists++
+			phase, rv := "Pending", "1"
+			if s.lists > 1 {
+				phase, rv = "Ready", "5"
+			}
+			_ = e.Encode(corev1.ConfigMapList{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMapList"},
+				ListMeta: metav1.ListMeta{ResourceVersion: rv},
+				Items:    []corev1.ConfigMap{configMap(phase, rv)},
+			})
+			return
+		}
+
+		s.watches = append(s.watches, r.URL.Query().Get("resourceVersion"))
+		if len(s.watches) == 1 {
+			_ = e.Encode(metav1.WatchEvent{Type: string(watch.Modified), Object: rawJSON(configMap("Pending", "2"))})
+			return
+		}
+		_ = e.Encode(metav1.WatchEvent{Type: string(watch.Error), Object: rawJSON(metav1.Status{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+			Status:   metav1.StatusFailure,
+			Code:     http.StatusGone,
+			Reason:   metav1.StatusReasonExpired,
+		})})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func configMap(phase, resourceVersion string) corev1.ConfigMap {
+	return corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "watched", Namespace: "test", ResourceVersion: resourceVersion},
+		Data:       map[string]string{"phase": phase},
+	}
+}
+
+func rawJSON(o interface{}) (r runtime.RawExtension) {
+	r.Raw, _ = json.Marshal(o)
+	return r
+}
+
+func Test_WatchForEventOnResourceUnstructured(t *testing.T) {
+	s := &fakeWatchServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	k, err := kube.New(&rest.Config{Host: srv.URL}, client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetNamespace("test")
+	u.SetName("watched")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ee := []string{}
+	we, err := k.WatchForEventOnResourceUnstructured(ctx, u, func(e watch.Event) (bool, error) {
+		o := e.Object.(*unstructured.Unstructured)
+		p, _, _ := unstructured.NestedString(o.Object, "data", "phase")
+		ee = append(ee, string(e.Type)+":"+p)
+		return p == "Ready", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-we; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"ADDED:Pending", "MODIFIED:Pending", "MODIFIED:Ready"}
+	if len(ee) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, ee)
+	}
+	for i := range expected {
+		if ee[i] != expected[i] {
+			t.Errorf("expected events %v, got %v", expected, ee)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.watches) != 2 || s.watches[0] != "1" || s.watches[1] != "2" {
+		t.Errorf("expected watches to resume from resourceVersions [1 2], got %v", s.watches)
+	}
+}
@@ -0,0 +1,72 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+
+	econtext "github.com/filariow/mctest/pkg/context"
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+const (
+	keyClusters     string = "topology-clusters"
+	keyProvisioners string = "topology-provisioners"
+)
+
+var ErrClusterNotFound error = fmt.Errorf("error cluster not found in topology")
+
+// NamedProvisioner is the provisioner of a named cluster of the topology
+type NamedProvisioner struct {
+	infra.ClusterProvisioner
+
+	Name string
+}
+
+// clusters
+func ClustersIntoContext(ctx context.Context, value map[string]kube.Client) context.Context {
+	return econtext.IntoContext(ctx, keyClusters, value)
+}
+
+func ClustersFromContext(ctx context.Context) (map[string]kube.Client, error) {
+	return econtext.FromContext[map[string]kube.Client](ctx, keyClusters)
+}
+
+func ClustersFromContextOrDie(ctx context.Context) map[string]kube.Client {
+	return econtext.FromContextOrDie[map[string]kube.Client](ctx, keyClusters)
+}
+
+// ClusterFromContext returns the client for the topology's cluster with the given name
+func ClusterFromContext(ctx context.Context, name string) (kube.Client, error) {
+	cc, err := ClustersFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := cc[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, name)
+	}
+	return c, nil
+}
+
+func ClusterFromContextOrDie(ctx context.Context, name string) kube.Client {
+	c, err := ClusterFromContext(ctx, name)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// provisioners
+func ProvisionersIntoContext(ctx context.Context, value []NamedProvisioner) context.Context {
+	return econtext.IntoContext(ctx, keyProvisioners, value)
+}
+
+func ProvisionersFromContext(ctx context.Context) ([]NamedProvisioner, error) {
+	return econtext.FromContext[[]NamedProvisioner](ctx, keyProvisioners)
+}
+
+func ProvisionersFromContextOrDie(ctx context.Context) []NamedProvisioner {
+	return econtext.FromContextOrDie[[]NamedProvisioner](ctx, keyProvisioners)
+}
@@ -0,0 +1,198 @@
+package topology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/kube"
+)
+
+var (
+	ErrInvalidTopology     error = fmt.Errorf("error invalid topology")
+	ErrProvisionerNotFound error = fmt.Errorf("error provisioner not found")
+)
+
+// Topology declares the clusters of a scenario
+type Topology struct {
+	Clusters []ClusterSpec `json:"clusters"`
+}
+
+// ClusterSpec declares a named cluster of a topology
+type ClusterSpec struct {
+	// Name used to address the cluster in steps
+	Name string `json:"name"`
+	// Role of the cluster, e.g. hub or spoke
+	Role string `json:"role,omitempty"`
+	// Name of the ProvisionerFactory used to build the cluster's provisioner
+	Provisioner string `json:"provisioner"`
+	// Provisioner specific overrides
+	Overrides map[string]interface{} `json:"overrides,omitempty"`
+}
+
+// ProvisionerFactory builds the provisioner for a cluster of the topology.
+// The returned provisioner is expected to provision exactly one cluster.
+type ProvisionerFactory func(ctx context.Context, scenarioId string, spec ClusterSpec) (infra.ClusterProvisioner, error)
+
+// Load reads and parses a topology file
+func Load(path string) (*Topology, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := Parse(d)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing topology %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse parses and validates a topology. Unknown fields are rejected.
+func Parse(data []byte) (*Topology, error) {
+	t := Topology{}
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTopology, err)
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks that clusters have a unique name and a provisioner
+func (t Topology) Validate() error {
+	if len(t.Clusters) == 0 {
+		return fmt.Errorf("%w: no clusters declared", ErrInvalidTopology)
+	}
+
+	nn := map[string]struct{}{}
+	for i, c := range t.Clusters {
+		if c.Name == "" {
+			return fmt.Errorf("%w: cluster %d has no name", ErrInvalidTopology, i)
+		}
+		if _, ok := nn[c.Name]; ok {
+			return fmt.Errorf("%w: cluster name %s is not unique", ErrInvalidTopology, c.Name)
+		}
+		if c.Provisioner == "" {
+			return fmt.Errorf("%w: cluster %s has no provisioner", ErrInvalidTopology, c.Name)
+		}
+		nn[c.Name] = struct{}{}
+	}
+	return nil
+}
+
+// Provision provisions all the clusters of the topology, in declaration order,
+// using the given factories. It injects into the returned context a kube.Client for each
+// cluster, addressable by name with ClusterFromContext, and the provisioners used by Unprovision.
+// Clients for clusters provisioned by an infra.NamespacedProvisioner are scoped to its namespace.
+func Provision(
+	ctx context.Context,
+	t Topology,
+	factories map[string]ProvisionerFactory,
+	scenarioId string,
+	opts client.Options,
+) (context.Context, error) {
+	if err := t.Validate(); err != nil {
+		return ctx, err
+	}
+
+	cc := map[string]kube.Client{}
+	pp := []NamedProvisioner{}
+	ctx = ProvisionersIntoContext(ctx, pp)
+	for _, c := range t.Clusters {
+		f, ok := factories[c.Provisioner]
+		if !ok {
+			return ctx, fmt.Errorf("%w: %s, required by cluster %s", ErrProvisionerNotFound, c.Provisioner, c.Name)
+		}
+
+		p, err := f(ctx, scenarioId, c)
+		if err != nil {
+			return ctx, fmt.Errorf("error building provisioner for cluster %s: %w", c.Name, err)
+		}
+		if n := p.NumClustersProvisionedInProvisionRound(); n != 1 {
+			return ctx, fmt.Errorf("%w: provisioner for cluster %s provisions %d clusters, expected 1", ErrInvalidTopology, c.Name, n)
+		}
+
+		// register the provisioner before provisioning, so partially provisioned clusters are cleaned up
+		pp = append(pp, NamedProvisioner{Name: c.Name, ClusterProvisioner: p})
+		ctx = ProvisionersIntoContext(ctx, pp)
+
+		log.Printf("provisioning cluster %s (role: %s) with provisioner %s", c.Name, c.Role, c.Provisioner)
+		k, err := provisionCluster(ctx, p, opts)
+		if err != nil {
+			return ctx, fmt.Errorf("error provisioning cluster %s: %w", c.Name, err)
+		}
+		cc[c.Name] = k
+	}
+
+	return ClustersIntoContext(ctx, cc), nil
+}
+
+// Unprovision unprovisions the clusters provisioned by Provision, in reverse order
+func Unprovision(ctx context.Context) error {
+	pp, err := ProvisionersFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for i := len(pp) - 1; i >= 0; i-- {
+		if err := pp[i].Unprovision(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("error unprovisioning cluster %s: %w", pp[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func provisionCluster(ctx context.Context, p infra.ClusterProvisioner, opts client.Options) (kube.Client, error) {
+	if err := p.Provision(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := p.WaitForProvisionedClusters(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg, err := clusterRESTConfig(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if np, ok := p.(infra.NamespacedProvisioner); ok {
+		return kube.NewNamespaced(cfg, opts, np.GetNamespace())
+	}
+	return kube.New(cfg, opts)
+}
+
+// clusterRESTConfig returns the rest.Config for the provisioner's cluster,
+// preferring refreshable credentials when the provisioner provides them
+func clusterRESTConfig(ctx context.Context, p infra.ClusterProvisioner) (*rest.Config, error) {
+	if cp, ok := p.(infra.CredentialSourcesProvider); ok {
+		ss, err := cp.GetAllCredentialSources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			return s.RESTConfig()
+		}
+		return nil, fmt.Errorf("expected one credential source from cluster provisioner, got none")
+	}
+
+	cfgs, err := p.GetAllAdminKubeconfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cfgs {
+		return &c, nil
+	}
+	return nil, fmt.Errorf("expected one kubeconfig from cluster provisioner, got none")
+}
@@ -0,0 +1,131 @@
+package topology_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/filariow/mctest/pkg/infra"
+	"github.com/filariow/mctest/pkg/topology"
+)
+
+type fakeProvisioner struct {
+	name   string
+	events *[]string
+}
+
+func (p *fakeProvisioner) GetAllAdminKubeconfigs(ctx context.Context) (map[string]rest.Config, error) {
+	return map[string]rest.Config{p.name: {Host: "https://127.0.0.1:6443"}}, nil
+}
+
+func (p *fakeProvisioner) Provision(ctx context.Context) error {
+	*p.events = append(*p.events, "provision "+p.name)
+	return nil
+}
+
+func (p *fakeProvisioner) NumClustersProvisionedInProvisionRound() int { return 1 }
+
+func (p *fakeProvisioner) Unprovision(ctx context.Context) error {
+	*p.events = append(*p.events, "unprovision "+p.name)
+	return nil
+}
+
+func (p *fakeProvisioner) WaitForProvisionedClusters(ctx context.Context) error { return nil }
+
+func Test_Parse(t *testing.T) {
+	t.Run("valid topology", func(t *testing.T) {
+		t.Parallel()
+
+		tp, err := topology.Parse([]byte(`
+clusters:
+- name: hub
+  role: hub
+  provisioner: clusterapi
+  overrides:
+    version: v1.28.0
+- name: spoke-1
+  role: spoke
+  provisioner: namespace
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(tp.Clusters); n != 2 {
+			t.Fatalf("expected 2 clusters, got %d", n)
+		}
+		if v := tp.Clusters[0].Overrides["version"]; v != "v1.28.0" {
+			t.Errorf("expected version override v1.28.0, got %v", v)
+		}
+	})
+
+	for n, d := range map[string]string{
+		"no clusters":         `clusters: []`,
+		"duplicated names":    "clusters:\n- {name: hub, provisioner: a}\n- {name: hub, provisioner: b}",
+		"missing provisioner": "clusters:\n- {name: hub}",
+		"unknown field":       "clusters:\n- {name: hub, provisioner: a, kind: x}",
+	} {
+		d := d
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := topology.Parse([]byte(d)); !errors.Is(err, topology.ErrInvalidTopology) {
+				t.Errorf("expected ErrInvalidTopology, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_ProvisionAndUnprovision(t *testing.T) {
+	events := []string{}
+	factory := func(ctx context.Context, scenarioId string, spec topology.ClusterSpec) (infra.ClusterProvisioner, error) {
+		return &fakeProvisioner{name: spec.Name + "-" + scenarioId, events: &events}, nil
+	}
+
+	tp := topology.Topology{Clusters: []topology.ClusterSpec{
+		{Name: "hub", Provisioner: "fake"},
+		{Name: "spoke-1", Provisioner: "fake"},
+	}}
+
+	ctx, err := topology.Provision(
+		context.Background(),
+		tp,
+		map[string]topology.ProvisionerFactory{"fake": factory},
+		"s1",
+		client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []string{"hub", "spoke-1"} {
+		if _, err := topology.ClusterFromContext(ctx, n); err != nil {
+			t.Errorf("expected cluster %s in context: %v", n, err)
+		}
+	}
+	if _, err := topology.ClusterFromContext(ctx, "spoke-2"); !errors.Is(err, topology.ErrClusterNotFound) {
+		t.Errorf("expected ErrClusterNotFound, got %v", err)
+	}
+
+	if err := topology.Unprovision(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"provision hub-s1", "provision spoke-1-s1", "unprovision spoke-1-s1", "unprovision hub-s1"}
+	if !slices.Equal(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+}
+
+func Test_ProvisionUnknownProvisioner(t *testing.T) {
+	tp := topology.Topology{Clusters: []topology.ClusterSpec{{Name: "hub", Provisioner: "unknown"}}}
+
+	_, err := topology.Provision(context.Background(), tp, nil, "s1", client.Options{})
+	if !errors.Is(err, topology.ErrProvisionerNotFound) {
+		t.Errorf("expected ErrProvisionerNotFound, got %v", err)
+	}
+}
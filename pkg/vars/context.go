@@ -0,0 +1,32 @@
+package vars
+
+import (
+	"context"
+
+	econtext "github.com/filariow/mctest/pkg/context"
+)
+
+const keyStore string = "scenario-variables"
+
+// store
+func StoreIntoContext(ctx context.Context, value *Store) context.Context {
+	return econtext.IntoContext(ctx, keyStore, value)
+}
+
+func StoreFromContext(ctx context.Context) (*Store, error) {
+	return econtext.FromContext[*Store](ctx, keyStore)
+}
+
+func StoreFromContextOrDie(ctx context.Context) *Store {
+	return econtext.FromContextOrDie[*Store](ctx, keyStore)
+}
+
+// ExpandFromContext expands text with the variables of the store in context.
+// If no store is found in context, every reference in text is undefined.
+func ExpandFromContext(ctx context.Context, text string) (string, error) {
+	s, err := StoreFromContext(ctx)
+	if err != nil {
+		return Expand(text, func(string) (string, bool) { return "", false })
+	}
+	return s.Expand(text)
+}
@@ -0,0 +1,134 @@
+package vars
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrUndefinedVariable error = fmt.Errorf("error undefined variable")
+	ErrInvalidReference  error = fmt.Errorf("error invalid variable reference")
+)
+
+// Store holds the variables of a scenario.
+// It is safe for concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	values map[string]string
+}
+
+func NewStore() *Store {
+	return &Store{values: map[string]string{}}
+}
+
+// Set sets the value of the variable with the given name, overwriting any previous value
+func (s *Store) Set(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[name] = value
+}
+
+// Get returns the value of the variable with the given name, if defined
+func (s *Store) Get(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// Values returns a copy of all the variables in the store
+func (s *Store) Values() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	vv := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		vv[k] = v
+	}
+	return vv
+}
+
+// Expand replaces the references to the variables of the store in text.
+// See Expand for the syntax.
+func (s *Store) Expand(text string) (string, error) {
+	return Expand(text, s.Get)
+}
+
+// Expand replaces every `${name}` in text with the value returned by lookup.
+// `$${` is an escape for a literal `${`, a `$` not followed by `{` is left untouched.
+//
+// Expansion is strict: if any referenced variable is not defined an error
+// wrapping ErrUndefinedVariable and listing all the undefined ones is returned.
+func Expand(text string, lookup func(name string) (string, bool)) (string, error) {
+	b := strings.Builder{}
+	undefined := map[string]struct{}{}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(text[i+1:], "${"):
+			// escaped reference
+			b.WriteString("${")
+			i += 2
+		case text[i+1] == '{':
+			e := strings.IndexByte(text[i+2:], '}')
+			if e == -1 {
+				return "", fmt.Errorf("%w: unterminated reference at offset %d", ErrInvalidReference, i)
+			}
+
+			n := strings.TrimSpace(text[i+2 : i+2+e])
+			if !isValidName(n) {
+				return "", fmt.Errorf("%w: invalid name '%s' at offset %d", ErrInvalidReference, n, i)
+			}
+
+			v, ok := lookup(n)
+			if !ok {
+				undefined[n] = struct{}{}
+			}
+			b.WriteString(v)
+			i += e + 2
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+
+	if len(undefined) > 0 {
+		nn := make([]string, 0, len(undefined))
+		for n := range undefined {
+			nn = append(nn, n)
+		}
+		sort.Strings(nn)
+		return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, strings.Join(nn, ", "))
+	}
+	return b.String(), nil
+}
+
+// isValidName checks the name is made of letters, digits, '_', '-' and '.' only
+func isValidName(n string) bool {
+	if n == "" {
+		return false
+	}
+
+	for _, r := range n {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Join is a convenience to build dotted variable names, e.g. Join("clusters", "hub", "namespace")
+func Join(parts ...string) string {
+	return strings.Join(parts, ".")
+}
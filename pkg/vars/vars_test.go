@@ -0,0 +1,93 @@
+package vars_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/filariow/mctest/pkg/vars"
+)
+
+func Test_Expand(t *testing.T) {
+	s := vars.NewStore()
+	s.Set("scenario.namespace", "test-abc")
+	s.Set("NAME", "foo")
+
+	t.Run("expands defined variables", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := s.Expand("namespace: ${scenario.namespace}\nname: ${ NAME }-bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := "namespace: test-abc\nname: foo-bar"; r != e {
+			t.Errorf("expected %q, got %q", e, r)
+		}
+	})
+
+	t.Run("leaves plain dollars and escaped references untouched", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := s.Expand("echo $HOME $${NAME} $")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := "echo $HOME ${NAME} $"; r != e {
+			t.Errorf("expected %q, got %q", e, r)
+		}
+	})
+
+	t.Run("fails on undefined variables", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := s.Expand("${missing} ${NAME} ${another}")
+		if !errors.Is(err, vars.ErrUndefinedVariable) {
+			t.Fatalf("expected ErrUndefinedVariable, got %v", err)
+		}
+		if e := "error undefined variable: another, missing"; err.Error() != e {
+			t.Errorf("expected error %q, got %q", e, err.Error())
+		}
+	})
+
+	t.Run("fails on invalid references", func(t *testing.T) {
+		t.Parallel()
+
+		for _, text := range []string{"${NAME", "${}", "${not valid}"} {
+			if _, err := s.Expand(text); !errors.Is(err, vars.ErrInvalidReference) {
+				t.Errorf("expected ErrInvalidReference for %q, got %v", text, err)
+			}
+		}
+	})
+}
+
+func Test_ExpandFromContext(t *testing.T) {
+	t.Run("expands with the store in context", func(t *testing.T) {
+		s := vars.NewStore()
+		s.Set("NAME", "foo")
+		ctx := vars.StoreIntoContext(context.Background(), s)
+
+		r, err := vars.ExpandFromContext(ctx, "name: ${NAME}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := "name: foo"; r != e {
+			t.Errorf("expected %q, got %q", e, r)
+		}
+	})
+
+	t.Run("fails on references without a store in context", func(t *testing.T) {
+		if _, err := vars.ExpandFromContext(context.Background(), "namespace: ${scenario.namespace}"); !errors.Is(err, vars.ErrUndefinedVariable) {
+			t.Errorf("expected ErrUndefinedVariable, got %v", err)
+		}
+	})
+
+	t.Run("expands text without references without a store in context", func(t *testing.T) {
+		r, err := vars.ExpandFromContext(context.Background(), "echo $HOME $${NAME}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := "echo $HOME ${NAME}"; r != e {
+			t.Errorf("expected %q, got %q", e, r)
+		}
+	})
+}